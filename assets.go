@@ -0,0 +1,112 @@
+package zmdocs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Static file copied to the output directory
+type Asset struct {
+	SourceFile string // Absolute path of the file to copy
+	Path       string // Output path relative to the output directory, using forward slashes
+}
+
+// Returns the output path for a file under the root directory, or an error if it's outside of it
+func assetPath(rootDir, file string) (string, error) {
+	rel, err := filepath.Rel(rootDir, file)
+
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the root directory", file)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// Expands the configured asset directories and globs into a list of files to copy
+func (p *Parser) loadAssets() ([]*Asset, error) {
+	assets := make([]*Asset, 0)
+
+	for _, a := range p.Config.Assets {
+		g := a
+
+		if !filepath.IsAbs(g) {
+			g = filepath.Join(p.Config.RootDir, g)
+		}
+
+		if info, err := os.Stat(g); err == nil && info.IsDir() {
+			g = filepath.Join(g, globStar, "*")
+		}
+
+		matches, err := Glob(g)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to find assets matching %s: %s", a, err.Error())
+		}
+
+		for _, m := range matches {
+			if p.Config.OutDir != "" && strings.HasPrefix(m, filepath.Clean(p.Config.OutDir)+string(filepath.Separator)) {
+				continue
+			}
+
+			path, err := assetPath(p.Config.RootDir, m)
+
+			if err != nil {
+				return nil, err
+			}
+
+			assets = append(assets, &Asset{
+				SourceFile: m,
+				Path:       path,
+			})
+		}
+	}
+
+	return assets, nil
+}
+
+// Returns the assets sorted by output path with duplicates removed
+func uniqueAssets(assets []*Asset) []*Asset {
+	seen := make(map[string]bool)
+	out := make([]*Asset, 0, len(assets))
+
+	for _, a := range assets {
+		if !seen[a.Path] {
+			seen[a.Path] = true
+			out = append(out, a)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+
+	return out
+}
+
+// Copies the asset to the output directory. Files that have the same size and
+// modification time as the source are not copied again.
+func (a *Asset) copy(fs OutputFS, outDir string) (string, error) {
+	dest := filepath.Join(outDir, filepath.FromSlash(a.Path))
+
+	src, err := os.Stat(a.SourceFile)
+
+	if err != nil {
+		return "", err
+	}
+
+	if d, err := fs.Stat(dest); err == nil && d.Size() == src.Size() && d.ModTime().Equal(src.ModTime()) {
+		return dest, nil
+	}
+
+	data, err := ioutil.ReadFile(a.SourceFile)
+
+	if err != nil {
+		return "", err
+	}
+
+	return dest, fs.WriteFile(dest, data, src.ModTime())
+}
@@ -19,9 +19,43 @@ func Generate(ctx *cli.Context) error {
 		}
 	}
 
-	if p, e := zmdocs.NewParserFromConfigFile(configPath); e != nil {
-		return fmt.Errorf("unable to parse config: %s", e)
-	} else if e := p.LoadSourceFiles(); e != nil {
+	if ctx.Bool("all-versions") {
+		outDir := ctx.String("out")
+
+		if outDir != "" {
+			var err error
+
+			if outDir, err = filepath.Abs(outDir); err != nil {
+				return err
+			}
+		}
+
+		if e := zmdocs.GenerateVersions(configPath, outDir, ctx.Int("jobs")); e != nil {
+			return fmt.Errorf("unable to generate versions: %s", e)
+		}
+
+		return nil
+	}
+
+	config, err := zmdocs.NewConfigFromFile(configPath)
+
+	if err != nil {
+		return fmt.Errorf("unable to parse config: %s", err)
+	}
+
+	if outDir := ctx.String("out"); outDir != "" {
+		if config.OutDir, err = filepath.Abs(outDir); err != nil {
+			return err
+		}
+	}
+
+	if jobs := ctx.Int("jobs"); jobs > 0 {
+		config.Jobs = jobs
+	}
+
+	p := zmdocs.NewParser(config)
+
+	if e := p.LoadSourceFiles(); e != nil {
 		return fmt.Errorf("unable to load files: %s", e)
 	} else if rnd, e := p.Renderer(); e != nil {
 		return fmt.Errorf("unable to create renderer: %s", e)
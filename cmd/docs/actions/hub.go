@@ -0,0 +1,173 @@
+package actions
+
+import (
+	"github.com/gorilla/websocket"
+	"sync"
+	"time"
+)
+
+const (
+	writeWait    = 10 * time.Second // Time allowed to write a message to a client
+	pongWait     = 60 * time.Second // Time allowed to read the next pong from a client
+	clientBuffer = 8                // Messages queued per client, clients that fall behind are dropped
+)
+
+// Broadcasts livereload messages to the connected websocket clients
+type hub struct {
+	pingPeriod time.Duration
+	pongWait   time.Duration
+
+	mtx       sync.Mutex
+	clients   map[*client]bool
+	lastError []byte // Error message of the last build, sent to new clients. Nil if the build succeeded.
+}
+
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+func newHub() *hub {
+	return &hub{
+		pingPeriod: pongWait * 9 / 10,
+		pongWait:   pongWait,
+		clients:    make(map[*client]bool),
+	}
+}
+
+// Registers the connection and serves it until it's closed
+func (h *hub) serve(conn *websocket.Conn) {
+	c := client{
+		conn: conn,
+		send: make(chan []byte, clientBuffer),
+	}
+
+	h.mtx.Lock()
+	h.clients[&c] = true
+
+	if h.lastError != nil {
+		c.send <- h.lastError
+	}
+
+	h.mtx.Unlock()
+
+	go h.readLoop(&c)
+	h.writeLoop(&c)
+}
+
+// Sends the message to all clients
+func (h *hub) broadcast(msg *reloadMessage) {
+	data := msg.bytes()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if msg.Type == messageError {
+		h.lastError = data
+	} else {
+		h.lastError = nil
+	}
+
+	for c := range h.clients {
+		select {
+		case c.send <- data:
+		default:
+			// the client isn't reading its messages
+			h.removeLocked(c)
+		}
+	}
+}
+
+// Reports whether the last broadcast message was an error
+func (h *hub) failed() bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return h.lastError != nil
+}
+
+// Returns the number of connected clients
+func (h *hub) count() int {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return len(h.clients)
+}
+
+// Disconnects all clients
+func (h *hub) close() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for c := range h.clients {
+		h.removeLocked(c)
+	}
+}
+
+func (h *hub) remove(c *client) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.removeLocked(c)
+}
+
+// Unregisters the client, closing its send channel stops its write loop. h.mtx must be held.
+func (h *hub) removeLocked(c *client) {
+	if h.clients[c] {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// Reads from the connection to process pongs and close frames, until the connection fails
+func (h *hub) readLoop(c *client) {
+	defer h.remove(c)
+
+	c.conn.SetReadLimit(512)
+	_ = c.conn.SetReadDeadline(time.Now().Add(h.pongWait))
+
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(h.pongWait))
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// Writes the queued messages and pings to the connection, until the client is removed or a write fails
+func (h *hub) writeLoop(c *client) {
+	ticker := time.NewTicker(h.pingPeriod)
+
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				h.remove(c)
+				return
+			}
+
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				h.remove(c)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"encoding/json"
+	"github.com/zyra/zmdocs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Types of the messages sent to the livereload clients
+const (
+	messageReload = "reload" // Reload the page
+	messageError  = "error"  // The build failed, show the error overlay
+	messageCSS    = "css"    // Only stylesheets changed, reload them without reloading the page
+)
+
+// Message sent to the livereload clients
+type reloadMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+func (m *reloadMessage) bytes() []byte {
+	data, _ := json.Marshal(m)
+	return data
+}
+
+var yamlLineRegex = regexp.MustCompile(`yaml: (?:unmarshal errors:\s+)?line (\d+)`)
+var templateLineRegex = regexp.MustCompile(`template: ([^:\s]+):(\d+)`)
+var filePathRegex = regexp.MustCompile(`(/[^\s:"]+\.(?:md|markdown|ya?ml|json|html|tmpl|tpl|css))(?::(\d+))?`)
+
+// Returns the error message for a failed build, with the file and line the error comes from if they can be found
+func errorMessage(err error, configPath string, config *zmdocs.ParserConfig) *reloadMessage {
+	msg := reloadMessage{
+		Type:    messageError,
+		Message: err.Error(),
+	}
+
+	if m := templateLineRegex.FindStringSubmatch(msg.Message); m != nil {
+		msg.File = m[1]
+		msg.Line, _ = strconv.Atoi(m[2])
+
+		if config != nil {
+			for _, t := range config.Templates {
+				if t.Name == m[1] {
+					msg.File = t.SourceFile
+				}
+			}
+		}
+	} else if m := filePathRegex.FindStringSubmatch(msg.Message); m != nil {
+		msg.File = m[1]
+		msg.Line, _ = strconv.Atoi(m[2])
+	}
+
+	if m := yamlLineRegex.FindStringSubmatch(msg.Message); m != nil && msg.Line == 0 {
+		msg.Line, _ = strconv.Atoi(m[1])
+
+		if msg.File == "" {
+			msg.File = configPath
+		}
+	}
+
+	if config != nil && filepath.IsAbs(msg.File) {
+		if rel, err := filepath.Rel(config.RootDir, msg.File); err == nil {
+			msg.File = rel
+		}
+	}
+
+	return &msg
+}
+
+// Returns the livereload script. The websocket URL is derived from the page location so it works
+// behind proxies and port forwarding, and uses wss when the page is served over TLS.
+func reloadScript(basePath string) string {
+	wsPath, _ := json.Marshal(basePath + reloadPath)
+
+	return `
+<script>(() => {
+let overlay = null;
+const clearOverlay = () => {
+	if (overlay) {
+		overlay.remove();
+		overlay = null;
+	}
+};
+const showError = msg => {
+	clearOverlay();
+	overlay = document.createElement("div");
+	overlay.setAttribute("role", "alert");
+	overlay.style.cssText = "position:fixed;top:0;right:0;bottom:0;left:0;z-index:2147483647;overflow:auto;padding:2rem;background:rgba(20,20,20,.92);color:#f8f8f2;font:14px/1.5 monospace";
+	const title = document.createElement("div");
+	title.style.cssText = "color:#ff6b6b;font-weight:bold;margin-bottom:1rem";
+	title.textContent = "Build failed" + (msg.file ? " in " + msg.file + (msg.line ? ":" + msg.line : "") : "");
+	const details = document.createElement("pre");
+	details.style.cssText = "white-space:pre-wrap;margin:0";
+	details.textContent = msg.message;
+	overlay.append(title, details);
+	document.body.appendChild(overlay);
+};
+const reloadCSS = () => document.querySelectorAll('link[rel="stylesheet"]').forEach(link => {
+	const url = new URL(link.href);
+	url.searchParams.set("t", Date.now());
+	link.href = url.toString();
+});
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + ` + string(wsPath) + `);
+ws.onopen = e => console.log("Livereload WS is open");
+ws.onmessage = e => {
+	const msg = JSON.parse(e.data);
+	switch (msg.type) {
+	case "error":
+		showError(msg);
+		break;
+	case "css":
+		clearOverlay();
+		reloadCSS();
+		break;
+	default:
+		location.reload();
+	}
+};
+ws.onclose = () => console.log("Livereload WS is closed");
+})()</script></body>
+`
+}
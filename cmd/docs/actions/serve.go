@@ -1,224 +1,401 @@
 package actions
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/websocket"
 	"github.com/urfave/cli"
 	"github.com/zyra/zmdocs"
-	"io/ioutil"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+func init() {
+	// types missing from the builtin table on some systems
+	for ext, typ := range map[string]string{
+		".woff":  "font/woff",
+		".woff2": "font/woff2",
+		".ttf":   "font/ttf",
+		".otf":   "font/otf",
+		".ico":   "image/x-icon",
+		".map":   "application/json",
+	} {
+		_ = mime.AddExtensionType(ext, typ)
+	}
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
-var chansMtx = sync.RWMutex{}
-var reloadChans = make(map[string]chan<- struct{})
-var chanId = 1
+// Number of ports after the requested one that are tried before falling back to a random free port
+const portAttempts = 10
+
+// Path of the livereload websocket, relative to the base path
+const reloadPath = "__reload"
+
+// Time to wait for more changes before rebuilding, editors often write several files on save
+const rebuildDelay = 150 * time.Millisecond
+
+// Time allowed for open requests to finish when the server stops
+const shutdownTimeout = 5 * time.Second
+
+// Output of a successful build. Snapshots are never modified once stored, every build creates a new one.
+type snapshot struct {
+	fs     zmdocs.OutputFS
+	outDir string
+}
+
+// Builds the docs, serves the last successful build and rebuilds when the sources change
+type devServer struct {
+	configPath string
+	basePath   string
+	write      bool // Write builds to the output directory instead of keeping them in memory
+	hub        *hub
+	current    atomic.Value // *snapshot
+}
+
+func newDevServer(configPath, basePath string, write bool) *devServer {
+	return &devServer{
+		configPath: configPath,
+		basePath:   normalizeBasePath(basePath),
+		write:      write,
+		hub:        newHub(),
+	}
+}
+
+// Returns the last successful build, or nil if there is none
+func (s *devServer) snapshot() *snapshot {
+	snap, _ := s.current.Load().(*snapshot)
+	return snap
+}
+
+// Builds the docs. The parser is returned whenever the config could be loaded, so its directories
+// can still be watched when the build fails.
+func (s *devServer) build() (*zmdocs.Parser, *snapshot, error) {
+	config, err := zmdocs.NewConfigFromFile(s.configPath)
 
-func serveWs(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		if _, ok := err.(websocket.HandshakeError); !ok {
-			log.Println(err)
-		}
-		return
+		return nil, nil, fmt.Errorf("unable to parse config: %s", err.Error())
 	}
 
-	ch := make(chan struct{})
-	chId := strconv.Itoa(chanId)
-	chanId++
-	chansMtx.Lock()
-	reloadChans[chId] = ch
-	chansMtx.Unlock()
+	// pages are linked relative to the host so they work under any host name
+	config.BaseURL = s.basePath
 
-	ws.SetCloseHandler(func(code int, text string) error {
-		chansMtx.Lock()
-		defer chansMtx.RUnlock()
-		close(ch)
-		delete(reloadChans, chId)
-		return nil
-	})
+	p := zmdocs.NewParser(config)
 
-	for {
-		<-ch
-		_ = ws.WriteMessage(1, []byte{})
+	if err := p.LoadSourceFiles(); err != nil {
+		return p, nil, fmt.Errorf("unable to load files: %s", err)
+	}
+
+	rnd, err := p.Renderer()
+
+	if err != nil {
+		return p, nil, fmt.Errorf("unable to create renderer: %s", err)
+	}
+
+	snap := snapshot{
+		fs:     zmdocs.DiskFS{},
+		outDir: rnd.OutDir,
+	}
+
+	if !s.write {
+		// every build gets a new file system so there are no stale files
+		snap.fs = zmdocs.NewMemFS()
+		rnd.FS = snap.fs
+	}
+
+	if err := rnd.Render(); err != nil {
+		return p, nil, fmt.Errorf("unable to render files: %s", err)
 	}
+
+	return p, &snap, nil
 }
 
-func Serve(ctx *cli.Context) error {
-	c, cancelFn := context.WithCancel(context.Background())
+// Rebuilds the docs and notifies the clients. Returns the new parser, or nil if the config couldn't be loaded.
+func (s *devServer) rebuild(cssOnly bool) *zmdocs.Parser {
+	p, snap, err := s.build()
 
-	configPath := ctx.String("config")
+	if err != nil {
+		log.Println(err)
 
-	if !filepath.IsAbs(configPath) {
-		if pwd, err := os.Getwd(); err != nil {
-			return err
-		} else {
-			configPath = filepath.Join(pwd, configPath)
+		var config *zmdocs.ParserConfig
+
+		if p != nil {
+			config = p.Config
 		}
+
+		s.hub.broadcast(errorMessage(err, s.configPath, config))
+
+		return p
+	}
+
+	s.current.Store(snap)
+
+	if cssOnly {
+		s.hub.broadcast(&reloadMessage{Type: messageCSS})
+	} else {
+		s.hub.broadcast(&reloadMessage{Type: messageReload})
 	}
 
-	var config *zmdocs.ParserConfig
-	var p *zmdocs.Parser
-	var e error
-	var rnd *zmdocs.Renderer
+	return p
+}
 
-	setupParser := func() {
-		if config, e = zmdocs.NewConfigFromFile(configPath); e != nil {
-			e = fmt.Errorf("unable to parse config: %s", e.Error())
+// Rebuilds when files in the watched directories change, until ctx is done
+func (s *devServer) watch(ctx context.Context, watcher *fsnotify.Watcher, p *zmdocs.Parser) {
+	// directories are watched rather than files so new, renamed and removed files are noticed
+	addWatchers := func() {
+		for _, dir := range p.WatchDirs() {
+			if err := watcher.Add(dir); err != nil {
+				log.Printf("unable to watch %s: %s", dir, err.Error())
+			}
 		}
+	}
+
+	addWatchers()
+
+	debounce := time.NewTimer(rebuildDelay)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	// files changed since the last rebuild
+	changed := make(map[string]bool)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
 
-		config.BaseURL = "http://localhost:3500"
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Printf("watcher error: %s", err.Error())
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if ev.Op == fsnotify.Chmod || p.InOutDir(ev.Name) {
+				continue
+			}
+
+			changed[ev.Name] = true
+			debounce.Reset(rebuildDelay)
 
-		p = zmdocs.NewParser(config)
+		case <-debounce.C:
+			cssOnly := !s.hub.failed()
 
-		if e = p.LoadSourceFiles(); e != nil {
-			e = fmt.Errorf("unable to load files: %s", e)
-		} else if rnd, e = p.Renderer(); e != nil {
-			e = fmt.Errorf("unable to create renderer: %s", e)
+			for name := range changed {
+				cssOnly = cssOnly && strings.ToLower(filepath.Ext(name)) == ".css"
+			}
+
+			changed = make(map[string]bool)
+
+			// keep the previous parser when the config is broken, its directories are still worth watching
+			if np := s.rebuild(cssOnly); np != nil {
+				p = np
+			}
+
+			addWatchers()
 		}
 	}
+}
+
+// Returns the handler serving the livereload websocket and the last successful build
+func (s *devServer) handler() http.Handler {
+	mux := http.NewServeMux()
 
-	render := func() {
-		if e = rnd.Render(); e != nil {
-			e = fmt.Errorf("unable to render files: %s", e)
+	mux.HandleFunc(s.basePath+reloadPath, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			if _, ok := err.(websocket.HandshakeError); !ok {
+				log.Println(err)
+			}
+			return
 		}
+
+		s.hub.serve(conn)
+	})
+
+	mux.Handle(s.basePath, http.StripPrefix(strings.TrimSuffix(s.basePath, "/"), http.HandlerFunc(s.serveFile)))
+
+	if s.basePath != "/" {
+		mux.Handle("/", http.RedirectHandler(s.basePath, http.StatusFound))
 	}
 
-	setupParser()
+	return mux
+}
 
-	if e != nil {
-		return e
+// Serves a file from the last successful build, pages get the livereload script injected
+func (s *devServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	// the snapshot is loaded once so the whole request is served from the same build
+	snap := s.snapshot()
+
+	if snap == nil {
+		http.Error(w, "the docs have not been built yet", http.StatusServiceUnavailable)
+		return
 	}
 
-	render()
+	fp := filepath.Join(snap.outDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
 
-	if e != nil {
-		return e
+	if filepath.Base(fp) != "index.html" {
+		if info, err := snap.fs.Stat(fp); err == nil && !info.IsDir() {
+			if fc, err := snap.fs.ReadFile(fp); err == nil {
+				http.ServeContent(w, r, info.Name(), info.ModTime(), bytes.NewReader(fc))
+				return
+			}
+		}
+	} else {
+		fp = filepath.Dir(fp)
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	fc, err := snap.fs.ReadFile(filepath.Join(fp, "index.html"))
 
 	if err != nil {
-		return fmt.Errorf("unable to start watcher: %s", err.Error())
+		http.NotFound(w, r)
+		return
 	}
 
-	defer watcher.Close()
+	html := strings.Replace(string(fc), "</body>", reloadScript(s.basePath), 1)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(html))
+}
 
-	if err := watcher.Add(configPath); err != nil {
-		return fmt.Errorf("unable to add config to watcher: %s", err.Error())
-	}
+// Listens on the requested port, or the next free one. Falls back to a random port if none of them are free.
+func listen(host string, port int) (net.Listener, error) {
+	var lastErr error
 
-	setupFileWatchers := func() {
-		for _, f := range p.Files {
-			_ = watcher.Add(f.SourceFile)
-		}
+	for i := 0; i < portAttempts; i++ {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port+i)))
 
-		for _, t := range p.Config.Templates {
-			_ = watcher.Add(t.SourceFile)
+		if err == nil {
+			return l, nil
 		}
+
+		lastErr = err
 	}
 
-	setupFileWatchers()
+	log.Printf("ports %d to %d are not available (%s), using a random port", port, port+portAttempts-1, lastErr.Error())
 
-	reload := func() {
-		chansMtx.RLock()
-		defer chansMtx.RUnlock()
-		for _, ch := range reloadChans {
-			ch <- struct{}{}
+	return net.Listen("tcp", net.JoinHostPort(host, "0"))
+}
+
+// Returns the base path with a leading and trailing slash
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(path.Clean("/"+basePath), "/")
+
+	if basePath == "" {
+		return "/"
+	}
+
+	return "/" + basePath + "/"
+}
+
+func Serve(ctx *cli.Context) error {
+	configPath := ctx.String("config")
+
+	if !filepath.IsAbs(configPath) {
+		if pwd, err := os.Getwd(); err != nil {
+			return err
+		} else {
+			configPath = filepath.Join(pwd, configPath)
 		}
 	}
 
-	go func() {
-		for {
-			select {
-			case <-c.Done():
-				return
+	s := newDevServer(configPath, ctx.String("base-path"), ctx.Bool("write"))
 
-			case ev, ok := <-watcher.Events:
-				if ! ok {
-					return
-				}
+	p, snap, err := s.build()
 
-				if ev.Op&fsnotify.Remove == fsnotify.Remove {
-					_ = watcher.Remove(ev.Name)
-				} else if ev.Op&fsnotify.Write == fsnotify.Write {
-					setupParser()
+	if err != nil {
+		return err
+	}
 
-					if e != nil {
-						fmt.Println(e)
-						continue
-					}
+	s.current.Store(snap)
 
-					render()
+	watcher, err := fsnotify.NewWatcher()
 
-					if e != nil {
-						fmt.Println(e)
-						continue
-					}
+	if err != nil {
+		return fmt.Errorf("unable to start watcher: %s", err.Error())
+	}
 
-					setupFileWatchers()
+	defer watcher.Close()
 
-					if e != nil {
-						fmt.Println(e)
-						e = nil
-					}
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		return fmt.Errorf("unable to add config directory to watcher: %s", err.Error())
+	}
 
-					reload()
-				}
-			}
-		}
-	}()
+	listener, err := listen(ctx.String("host"), ctx.Int("port"))
 
-	go func() {
-		http.HandleFunc("/reload", serveWs)
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			p := filepath.Join(p.Config.OutDir, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "index.html"), "index.html")
-			if fc, e := ioutil.ReadFile(p); e != nil {
-				http.NotFound(w, r)
-				return
-			} else {
-				html := strings.Replace(string(fc), "</body>", `
-<script>(() => {
-const ws = new WebSocket("ws://localhost:3500/reload");
-ws.onopen = e => console.log("Livereload WS is open");
-ws.onmessage = () => location.reload();
-ws.onclose = () => console.log("Livereload WS is closed");
-})()</script></body>
-`, 1)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(html))
-			}
-		})
-		if err := http.ListenAndServe(":3500", nil); err != nil {
-			if context.Canceled != nil {
-				return
-			}
+	if err != nil {
+		return fmt.Errorf("unable to listen: %s", err.Error())
+	}
 
-			fmt.Printf("unable to serve: %s", err.Error())
-			cancelFn()
-		}
+	c, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	go s.watch(c, watcher, p)
+
+	srv := http.Server{
+		Handler: s.handler(),
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- srv.Serve(listener)
 	}()
 
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt)
+	host := ctx.String("host")
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	log.Printf("serving docs at http://%s%s", net.JoinHostPort(host, strconv.Itoa(port)), s.basePath)
+
+	sig := make(chan os.Signal, 1)
+	// docker stop sends SIGTERM
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
 
 	select {
-	case <-ch:
-		cancelFn()
-	case <-c.Done():
+	case <-sig:
+		log.Println("shutting down")
+
+	case err := <-serveErr:
+		return fmt.Errorf("unable to serve: %s", err.Error())
+	}
+
+	cancelFn()
+
+	// websocket connections are hijacked, the server doesn't close them on shutdown
+	s.hub.close()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("unable to shut down: %s", err.Error())
 	}
 
 	return nil
@@ -0,0 +1,384 @@
+package actions
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/zyra/zmdocs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Starts a test server for s and returns it with the livereload websocket URL and a function that stops it
+func startServer(s *devServer) (*httptest.Server, string, func()) {
+	srv := httptest.NewServer(s.handler())
+	stop := func() {
+		s.hub.close()
+		srv.Close()
+	}
+
+	return srv, "ws" + strings.TrimPrefix(srv.URL, "http") + s.basePath + reloadPath, stop
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+
+	if err != nil {
+		t.Fatalf("unable to connect: %s", err)
+	}
+
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) *reloadMessage {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+
+	if err != nil {
+		t.Fatalf("unable to read message: %s", err)
+	}
+
+	var msg reloadMessage
+
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("invalid message %q: %s", data, err)
+	}
+
+	return &msg
+}
+
+// Waits until the hub has n clients
+func waitForClients(t *testing.T, h *hub, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for h.count() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d clients, got %d", n, h.count())
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newMemSnapshot(t *testing.T, files map[string]string) *snapshot {
+	t.Helper()
+
+	fs := zmdocs.NewMemFS()
+	outDir := "/out"
+
+	for name, content := range files {
+		if err := fs.WriteFile(filepath.Join(outDir, name), []byte(content), time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &snapshot{fs: fs, outDir: outDir}
+}
+
+func TestHubBroadcast(t *testing.T) {
+	s := newDevServer("", "/", false)
+	_, url, stop := startServer(s)
+	defer stop()
+
+	conns := []*websocket.Conn{dial(t, url), dial(t, url), dial(t, url)}
+
+	for _, conn := range conns {
+		defer conn.Close()
+	}
+
+	waitForClients(t, s.hub, len(conns))
+
+	s.hub.broadcast(&reloadMessage{Type: messageReload})
+	s.hub.broadcast(&reloadMessage{Type: messageCSS})
+
+	for _, conn := range conns {
+		if msg := readMessage(t, conn); msg.Type != messageReload {
+			t.Errorf("expected %q message, got %q", messageReload, msg.Type)
+		}
+
+		if msg := readMessage(t, conn); msg.Type != messageCSS {
+			t.Errorf("expected %q message, got %q", messageCSS, msg.Type)
+		}
+	}
+}
+
+func TestHubLastError(t *testing.T) {
+	s := newDevServer("", "/", false)
+	_, url, stop := startServer(s)
+	defer stop()
+
+	s.hub.broadcast(&reloadMessage{Type: messageError, Message: "broken", File: "index.md", Line: 3})
+
+	if !s.hub.failed() {
+		t.Fatal("expected the hub to report a failed build")
+	}
+
+	first := dial(t, url)
+	defer first.Close()
+
+	msg := readMessage(t, first)
+
+	if msg.Type != messageError || msg.Message != "broken" || msg.File != "index.md" || msg.Line != 3 {
+		t.Errorf("unexpected message for new client: %+v", msg)
+	}
+
+	s.hub.broadcast(&reloadMessage{Type: messageReload})
+
+	if s.hub.failed() {
+		t.Fatal("expected the error to be cleared after a successful build")
+	}
+
+	conn := dial(t, url)
+	defer conn.Close()
+
+	waitForClients(t, s.hub, 2)
+
+	// a new client gets nothing until the next broadcast
+	s.hub.broadcast(&reloadMessage{Type: messageCSS})
+
+	if msg := readMessage(t, conn); msg.Type != messageCSS {
+		t.Errorf("expected %q message, got %q", messageCSS, msg.Type)
+	}
+}
+
+func TestHubRemovesClosedClients(t *testing.T) {
+	s := newDevServer("", "/", false)
+	_, url, stop := startServer(s)
+	defer stop()
+
+	a, b := dial(t, url), dial(t, url)
+	waitForClients(t, s.hub, 2)
+
+	_ = a.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_ = a.Close()
+	waitForClients(t, s.hub, 1)
+
+	_ = b.Close()
+	waitForClients(t, s.hub, 0)
+
+	// broadcasting without clients must not block
+	s.hub.broadcast(&reloadMessage{Type: messageReload})
+}
+
+func TestHubClose(t *testing.T) {
+	s := newDevServer("", "/", false)
+	_, url, stop := startServer(s)
+	defer stop()
+
+	conn := dial(t, url)
+	defer conn.Close()
+
+	waitForClients(t, s.hub, 1)
+
+	s.hub.close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected a going away close frame, got %v", err)
+	}
+
+	if n := s.hub.count(); n != 0 {
+		t.Errorf("expected no clients after close, got %d", n)
+	}
+}
+
+func TestHubPing(t *testing.T) {
+	s := newDevServer("", "/", false)
+	s.hub.pingPeriod = 20 * time.Millisecond
+	_, url, stop := startServer(s)
+	defer stop()
+
+	conn := dial(t, url)
+	defer conn.Close()
+
+	pinged := make(chan struct{}, 1)
+
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	// control frames are only processed while reading
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a ping from the server")
+	}
+}
+
+func TestHubDropsUnresponsiveClients(t *testing.T) {
+	s := newDevServer("", "/", false)
+	s.hub.pingPeriod = 20 * time.Millisecond
+	s.hub.pongWait = 50 * time.Millisecond
+	_, url, stop := startServer(s)
+	defer stop()
+
+	// the client never reads, so it never answers pings
+	conn := dial(t, url)
+	defer conn.Close()
+
+	waitForClients(t, s.hub, 1)
+	waitForClients(t, s.hub, 0)
+}
+
+func TestServeFile(t *testing.T) {
+	s := newDevServer("", "/docs", false)
+	s.current.Store(newMemSnapshot(t, map[string]string{
+		"index.html":       "<html><body>home</body></html>",
+		"guide/index.html": "<html><body>guide</body></html>",
+		"style.css":        "body{}",
+	}))
+
+	srv, _, stop := startServer(s)
+	defer stop()
+
+	client := http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+
+		res, err := client.Get(srv.URL + path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+
+		return res, string(body)
+	}
+
+	for _, path := range []string{"/docs/", "/docs/index.html", "/docs/guide/", "/docs/guide"} {
+		res, body := get(path)
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, res.StatusCode)
+		}
+
+		if !strings.Contains(body, "/docs/"+reloadPath) {
+			t.Errorf("%s: expected the livereload script to be injected", path)
+		}
+	}
+
+	if res, body := get("/docs/style.css"); res.StatusCode != http.StatusOK || body != "body{}" {
+		t.Errorf("unexpected stylesheet response %d %q", res.StatusCode, body)
+	} else if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("unexpected stylesheet content type %q", ct)
+	}
+
+	if res, _ := get("/docs/missing/"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", res.StatusCode)
+	}
+
+	if res, _ := get("/"); res.StatusCode != http.StatusFound || res.Header.Get("Location") != "/docs/" {
+		t.Errorf("expected a redirect to the base path, got %d %q", res.StatusCode, res.Header.Get("Location"))
+	}
+}
+
+func TestServeFileWithoutBuild(t *testing.T) {
+	s := newDevServer("", "/", false)
+	srv, _, stop := startServer(s)
+	defer stop()
+
+	res, err := http.Get(srv.URL + "/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", res.StatusCode)
+	}
+}
+
+func TestSnapshotSwap(t *testing.T) {
+	s := newDevServer("", "/", false)
+	s.current.Store(newMemSnapshot(t, map[string]string{"index.html": "<body>0</body>"}))
+	srv, _, stop := startServer(s)
+	defer stop()
+
+	// snapshots are built up front, newMemSnapshot can't fail the test from another goroutine
+	snaps := make([]*snapshot, 64)
+
+	for i := range snaps {
+		snaps[i] = newMemSnapshot(t, map[string]string{"index.html": "<body>" + strings.Repeat("x", i) + "</body>"})
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			s.current.Store(snaps[i%len(snaps)])
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 25; j++ {
+				res, err := http.Get(srv.URL + "/")
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				body, _ := ioutil.ReadAll(res.Body)
+				_ = res.Body.Close()
+
+				if res.StatusCode != http.StatusOK || !strings.Contains(string(body), reloadPath) {
+					t.Errorf("unexpected response %d %q", res.StatusCode, body)
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+}
@@ -43,6 +43,20 @@ func main() {
 					EnvVar: "ZMDOC_CONFIG",
 					Value:  "./.docs.yaml",
 				},
+				cli.StringFlag{
+					Name:   "out, o",
+					Usage:  "Output directory, overrides outDir from the config file",
+					EnvVar: "ZMDOC_OUT",
+				},
+				cli.IntFlag{
+					Name:   "jobs, j",
+					Usage:  "Number of pages rendered in parallel, defaults to the number of CPUs",
+					EnvVar: "ZMDOC_JOBS",
+				},
+				cli.BoolFlag{
+					Name:  "all-versions",
+					Usage: "Build every version listed in the versions config from git",
+				},
 			},
 		},
 		{
@@ -57,6 +71,27 @@ func main() {
 					EnvVar: "ZMDOC_CONFIG",
 					Value:  "./.docs.yaml",
 				},
+				cli.StringFlag{
+					Name:   "host",
+					Usage:  "Host to listen on, defaults to all interfaces",
+					EnvVar: "ZMDOC_HOST",
+				},
+				cli.IntFlag{
+					Name:   "port, p",
+					Usage:  "Port to listen on, the next free port is used if it's taken",
+					EnvVar: "ZMDOC_PORT",
+					Value:  3500,
+				},
+				cli.BoolFlag{
+					Name:  "write",
+					Usage: "Write the site to the output directory, by default it's only kept in memory",
+				},
+				cli.StringFlag{
+					Name:   "base-path",
+					Usage:  "Path the docs are served under, e.g. when running behind a proxy",
+					EnvVar: "ZMDOC_BASE_PATH",
+					Value:  "/",
+				},
 			},
 		},
 	}
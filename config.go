@@ -7,6 +7,9 @@ import (
 	"path/filepath"
 )
 
+// Output directory used when none is configured
+const DefaultOutDir = "docs"
+
 // Base page properties that can be found in pages and page patterns
 type BasePage struct {
 	Name         string `yaml:"name"`         // Page name
@@ -24,11 +27,32 @@ type Page struct {
 	Title    string `yaml:"title,omitempty"` // Page title to be used in the html `<title>` tag.
 }
 
-// Auto / Pattern page configuration
+// Auto / Pattern page configuration.
+// Name, Path, Title and MenuGroup are Go templates executed against a PatternMatch.
 type PagePattern struct {
 	BasePage   `yaml:",inline"`
-	SourceGlob string `yaml:"sourceGlob"` // Glob to find files to process under this rule
-	Pattern    string `yaml:"pattern"`    // Pattern to use to extract relevant information that can be used in other page properties
+	Title      string   `yaml:"title"`      // Page title template. Defaults to the first heading of the page.
+	SourceGlob string   `yaml:"sourceGlob"` // Glob to find files to process under this rule, `**` matches any number of directories
+	Exclude    []string `yaml:"exclude"`    // Globs of files to skip, relative to the root directory
+	Pattern    string   `yaml:"pattern"`    // Pattern to use to extract relevant information that can be used in other page properties
+}
+
+// Go packages to generate API reference pages from, one page per package.
+// Name, Path, Title and MenuGroup are Go templates executed against a PatternMatch with the
+// `name`, `importPath` and `relDir` groups set. Package pages have no edit link, editOnGithub is ignored.
+type GoPackagesSource struct {
+	BasePage `yaml:",inline"`
+	Title    string   `yaml:"title"`   // Page title template. Defaults to "Package {{ .Groups.name }}".
+	Dir      string   `yaml:"dir"`     // Directory to search for packages, relative to the root directory
+	Exclude  []string `yaml:"exclude"` // Globs of directories to skip, relative to the root directory
+}
+
+// OpenAPI 3 spec to generate reference pages from. The spec file is set with "source".
+// Name, Path, Title and MenuGroup are Go templates, see GetFilesForOpenAPISpec for the available groups.
+type OpenAPISource struct {
+	BasePage `yaml:",inline"`
+	Title    string `yaml:"title"`   // Page title template. Defaults to the tag name, operation summary or "Models".
+	GroupBy  string `yaml:"groupBy"` // Either "tag" (default) for one page per tag, or "operation" for one page per operation
 }
 
 // Go template used to render pages
@@ -45,20 +69,41 @@ type MenuItem struct {
 	Items  []*MenuItem `yaml:"items"` // sub menu items (optional)
 	Group  bool        `yaml:"group"` // whether this is a group heading
 	Active bool        `yaml:"-"`     // This property is populated and used in the rendering stage to determine whether it should have an `.active` class added.
+
+	pagePath bool // Link is a page path, linked relative to the site root of every page
 }
 
 // Main parser config
 type ParserConfig struct {
-	RootDir     string         `yaml:"rootDir"`      // root directory of project, defaults to the config file directory if initialized with NewParserFromConfigFile
-	OutDir      string         `yaml:"outDir"`       // output directory for generated docs
-	Pages       []*Page        `yaml:"pages"`        // list of pages to render
-	AutoPages   []*PagePattern `yaml:"pagePatterns"` // list of patterns to derive pages from
-	Templates   []*Template    `yaml:"templates"`    // list of template files
-	MenuItems   []*MenuItem    `yaml:"menuItems"`    // menu items
-	SiteTitle   string         `yaml:"siteTitle"`    // Site title
-	Description string         `yaml:"description"`  // Site description to be used in the `<meta name="description" value"...">` HTML tag
-	Repo        string         `yaml:"repo"`         // Project repo URL
-	BaseURL     string         `yaml:"baseUrl"`      // Base public URL for the generated docs
+	RootDir     string              `yaml:"rootDir"`      // root directory of project, defaults to the config file directory if initialized with NewParserFromConfigFile
+	OutDir      string              `yaml:"outDir"`       // output directory for generated docs, defaults to "docs"
+	CleanOutDir bool                `yaml:"cleanOutDir"`  // delete files in the output directory that were not produced by the current build
+	Pages       []*Page             `yaml:"pages"`        // list of pages to render
+	AutoPages   []*PagePattern      `yaml:"pagePatterns"` // list of patterns to derive pages from
+	GoPackages  []*GoPackagesSource `yaml:"goPackages"`   // list of directories to generate Go API reference pages from
+	OpenAPI     []*OpenAPISource    `yaml:"openapi"`      // list of OpenAPI 3 specs to generate reference pages from
+	Templates   []*Template         `yaml:"templates"`    // list of template files
+	PartialsDir string              `yaml:"partialsDir"`  // directory containing partial templates that are loaded into every template, defaults to "partials"
+	MenuItems   []*MenuItem         `yaml:"menuItems"`    // menu items
+	SiteTitle   string              `yaml:"siteTitle"`    // Site title
+	Description string              `yaml:"description"`  // Site description to be used in the `<meta name="description" value"...">` HTML tag
+	Repo        string              `yaml:"repo"`         // Project repo URL
+	RepoType    string              `yaml:"repoType"`     // Repo host type (github, gitlab, gitea or bitbucket), detected from the repo URL if not set
+	RepoBranch  string              `yaml:"repoBranch"`   // Branch used for "Edit" links, defaults to "master"
+	RepoDir     string              `yaml:"repoDir"`      // Path of the root directory inside the repo, used for "Edit" links when docs are not at the repo root
+	BaseURL     string              `yaml:"baseUrl"`      // Base public URL for the generated docs
+	Highlight   *HighlightConfig    `yaml:"highlight"`    // Syntax highlighting of fenced code blocks, enabled by default
+	TOC         *TOCConfig          `yaml:"toc"`          // Table of contents settings, can be overridden in the front matter of each page
+	StrictLinks bool                `yaml:"strictLinks"`  // Fail the build on broken internal links instead of logging a warning
+	Assets      []string            `yaml:"assets"`       // Directories or globs of static files to copy to the output directory, relative to the root directory
+	Search      *SearchConfig       `yaml:"search"`       // Client side search index, enabled by default
+	Sitemap     *SitemapConfig      `yaml:"sitemap"`      // sitemap.xml generation
+	Robots      *RobotsConfig       `yaml:"robots"`       // robots.txt generation
+	Versions    *VersionsConfig     `yaml:"versions"`     // Versions built with `generate --all-versions`
+	Version     string              `yaml:"-"`            // Version being built, set by GenerateVersions
+	Languages   []*Language         `yaml:"languages"`    // Languages the docs are published in, the first one is the default. Pages missing a translation fall back to it.
+	Jobs        int                 `yaml:"jobs"`         // Number of pages parsed and rendered in parallel, defaults to the number of CPUs
+	Processors  []*ProcessorConfig  `yaml:"processors"`   // External commands called during the build, see ProcessorConfig
 }
 
 // Loads configuration from a .yml / .yaml file
@@ -74,7 +119,22 @@ func NewConfigFromFile(path string) (*ParserConfig, error) {
 	}
 
 	config.RootDir = filepath.Dir(path)
-	config.OutDir = filepath.Join(config.RootDir, config.OutDir)
+
+	if config.OutDir == "" {
+		config.OutDir = DefaultOutDir
+	}
+
+	if !filepath.IsAbs(config.OutDir) {
+		config.OutDir = filepath.Join(config.RootDir, config.OutDir)
+	}
+
+	if config.PartialsDir == "" {
+		config.PartialsDir = "partials"
+	}
+
+	if !filepath.IsAbs(config.PartialsDir) {
+		config.PartialsDir = filepath.Join(config.RootDir, config.PartialsDir)
+	}
 
 	for _, t := range config.Templates {
 		if !filepath.IsAbs(t.SourceFile) {
@@ -0,0 +1,104 @@
+package zmdocs
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Supported repository hosts
+const (
+	RepoTypeGitHub    = "github"
+	RepoTypeGitLab    = "gitlab"
+	RepoTypeGitea     = "gitea"
+	RepoTypeBitbucket = "bitbucket"
+)
+
+// Branch used to build edit links when none is configured
+const DefaultRepoBranch = "master"
+
+// Returns the repository host type from the config, or detects it from the repo URL.
+// Unknown hosts are treated like GitHub.
+func (c *ParserConfig) RepoHostType() string {
+	switch t := strings.ToLower(c.RepoType); t {
+	case RepoTypeGitHub, RepoTypeGitLab, RepoTypeGitea, RepoTypeBitbucket:
+		return t
+	case "":
+	default:
+		log.Warnf("unknown repo type %s, using %s", c.RepoType, RepoTypeGitHub)
+		return RepoTypeGitHub
+	}
+
+	u, err := url.Parse(c.Repo)
+
+	if err != nil {
+		return RepoTypeGitHub
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	switch {
+	case strings.Contains(host, "gitlab"):
+		return RepoTypeGitLab
+	case strings.Contains(host, "bitbucket"):
+		return RepoTypeBitbucket
+	case strings.Contains(host, "gitea"), strings.Contains(host, "codeberg"):
+		return RepoTypeGitea
+	default:
+		return RepoTypeGitHub
+	}
+}
+
+// Returns the URL of the page that allows editing the provided source file on the repo host.
+// Returns an empty string if no repo is configured or the file is outside RootDir.
+func (c *ParserConfig) EditURL(sourceFile string) string {
+	if c.Repo == "" || sourceFile == "" {
+		return ""
+	}
+
+	rel, err := filepath.Rel(c.RootDir, sourceFile)
+
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	repoPath := filepath.ToSlash(filepath.Join(c.RepoDir, rel))
+	repo := strings.TrimSuffix(strings.TrimSuffix(c.Repo, "/"), ".git")
+	branch := c.RepoBranch
+
+	if branch == "" {
+		branch = DefaultRepoBranch
+	}
+
+	switch c.RepoHostType() {
+	case RepoTypeGitLab:
+		return fmt.Sprintf("%s/-/edit/%s/%s", repo, branch, repoPath)
+	case RepoTypeGitea:
+		return fmt.Sprintf("%s/_edit/%s/%s", repo, branch, repoPath)
+	case RepoTypeBitbucket:
+		return fmt.Sprintf("%s/src/%s/%s?mode=edit&at=%s", repo, branch, repoPath, url.QueryEscape(branch))
+	default:
+		return fmt.Sprintf("%s/edit/%s/%s", repo, branch, repoPath)
+	}
+}
+
+// Returns the URL to open a new issue about the page with the provided title on the repo host.
+// Returns an empty string if no repo is configured.
+func (c *ParserConfig) IssueURL(title string) string {
+	if c.Repo == "" {
+		return ""
+	}
+
+	repo := strings.TrimSuffix(strings.TrimSuffix(c.Repo, "/"), ".git")
+	issueTitle := strings.Replace(url.QueryEscape(fmt.Sprintf("Docs: %s", title)), "+", "%20", -1)
+
+	switch c.RepoHostType() {
+	case RepoTypeGitLab:
+		return fmt.Sprintf("%s/-/issues/new?issue[title]=%s", repo, issueTitle)
+	case RepoTypeBitbucket:
+		return fmt.Sprintf("%s/issues/new?title=%s", repo, issueTitle)
+	default:
+		return fmt.Sprintf("%s/issues/new?title=%s", repo, issueTitle)
+	}
+}
@@ -1,9 +1,12 @@
 package zmdocs
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/russross/blackfriday"
-	"html/template"
 	"io/ioutil"
+	"path"
+	"strings"
 )
 
 var blackFridayExtensions = blackfriday.WithExtensions(blackfriday.CommonExtensions | blackfriday.AutoHeadingIDs | blackfriday.Autolink | blackfriday.Footnotes)
@@ -13,36 +16,107 @@ var blackFridayRndOpts = blackfriday.HTMLRendererParameters{
 
 type File struct {
 	BasePage
-	Title string
+	Title     string
+	Params    map[string]interface{} // Extra front matter keys that don't map to a page property
+	TOC       *TOCConfig             // Table of contents config from the front matter, overrides the global config
+	NoSitemap bool                   // Leave the page out of sitemap.xml, set with `sitemap: false` in the front matter
+
+	content []byte // Markdown source with the front matter stripped
+	loaded  bool
+	doc     *blackfriday.Node
+	rnd     blackfriday.Renderer
+	anchors map[string]bool
+	assets  []*Asset // Local images referenced by the page
+	hash    string   // Hash of the source file
+
+	lang           *Language // Language of the page, nil if no languages are configured
+	translationKey string    // Identifies the page across languages
+	fallback       bool      // Whether the page is the default language source used in place of a missing translation
 }
 
-func (f *File) RenderContext(p *Parser) (*RenderContext, error) {
-	var fc []byte
-	var err error
+// Returns a page generated from markdown instead of a source file, e.g. by a plugin
+func NewGeneratedFile(page BasePage, title string, markdown []byte) *File {
+	return &File{
+		BasePage: page,
+		Title:    title,
+		Params:   make(map[string]interface{}),
+		content:  markdown,
+		loaded:   true,
+	}
+}
 
-	if fc, err = ioutil.ReadFile(f.SourceFile); err != nil {
-		return nil, err
+// Reads the source file and applies its front matter (if any) over the page properties
+func (f *File) Load() error {
+	if f.loaded {
+		return nil
 	}
 
-	rnd := blackfriday.NewHTMLRenderer(blackFridayRndOpts)
-	o := blackfriday.Run(fc, blackFridayExtensions, blackfriday.WithRenderer(rnd))
+	fc, err := ioutil.ReadFile(f.SourceFile)
 
-	if f.Title == "" {
-		mp := blackfriday.New(blackFridayExtensions, blackfriday.WithRenderer(rnd))
+	if err != nil {
+		return err
+	}
+
+	if f.Params == nil {
+		f.Params = make(map[string]interface{})
+	}
+
+	fm, body := SplitFrontMatter(fc)
+
+	if fm != nil {
+		if err := f.applyFrontMatter(fm); err != nil {
+			return fmt.Errorf("%s: %s", f.SourceFile, err.Error())
+		}
+	}
+
+	f.content = body
+	f.hash = hashBytes(fc)
+	f.loaded = true
+
+	return nil
+}
 
-		a := mp.Parse(fc)
-		it := a.FirstChild
-		for it != nil && f.Title == "" {
-			if it.Type == blackfriday.Heading && it.Level == 1 && it.FirstChild != nil && it.FirstChild.Type == blackfriday.Text {
-				f.Title = string(it.FirstChild.Literal)
+// Parses the markdown source, sets the title from the first heading if there is none yet and collects the page anchors
+func (f *File) Parse(p *Parser) error {
+	if err := f.Load(); err != nil {
+		return err
+	}
+
+	src, err := p.transformMarkdown(f, f.content)
+
+	if err != nil {
+		return err
+	}
+
+	f.rnd = newMarkdownRenderer(p.Config)
+	f.doc = blackfriday.New(blackFridayExtensions, blackfriday.WithRenderer(f.rnd)).Parse(src)
+	uniqueHeadingIDs(f.doc)
+	f.anchors = documentAnchors(f.doc)
+
+	if f.Title == "" {
+		for it := f.doc.FirstChild; it != nil; it = it.Next {
+			if it.Type == blackfriday.Heading && it.Level == 1 {
+				f.Title = nodeText(it)
 				break
 			}
+		}
+	}
+
+	return nil
+}
 
-			it = it.Next
+func (f *File) RenderContext(p *Parser) (*RenderContext, error) {
+	if f.doc == nil {
+		if err := f.Parse(p); err != nil {
+			return nil, err
 		}
 	}
 
-	ctx := NewRenderContext(f, p.Config, template.HTML(o))
+	// the markdown is rendered with the page, so it's skipped for pages that haven't changed
+	ctx := NewRenderContext(f, p.Config, "")
+	ctx.doc = f.doc
+	ctx.rnd = f.rnd
+	ctx.inputs.Source = f.sourceHash()
 
 	if f.Path == "" || f.Path == "/" {
 		if p.Config.BaseURL != "" {
@@ -50,14 +124,83 @@ func (f *File) RenderContext(p *Parser) (*RenderContext, error) {
 		}
 	}
 
+	pagePath := normalizePagePath(f.Path)
+
+	if p.Config.BaseURL != "" {
+		ctx.RootURL = strings.TrimSuffix(p.Config.BaseURL, "/") + "/"
+	} else if pagePath == "" {
+		ctx.RootURL = "./"
+	} else {
+		ctx.RootURL = strings.Repeat("../", strings.Count(pagePath, "/")+1)
+	}
+
+	if link := p.Config.Highlight.StylesheetLink(); link != "" {
+		// the stylesheet is at the site root, nested pages need the path to it
+		ctx.Stylesheets = append(ctx.Stylesheets, ctx.RootURL+link)
+	}
+
+	p.Config.Versions.apply(ctx, p.Config.Version, ctx.RootURL)
+
+	if f.lang != nil {
+		ctx.Lang = f.lang
+		ctx.Untranslated = f.fallback
+		ctx.MenuItems = p.menus[f.lang.Code]
+	}
+
+	if pagePath != "" {
+		pagePath += "/"
+	}
+
+	ctx.SearchIndex = p.Config.Search.IndexPath()
+
+	if f.lang != nil && ctx.SearchIndex != "" {
+		// every language has its own index
+		ctx.SearchIndex = path.Join(f.lang.Code, ctx.SearchIndex)
+	}
+
+	ctx.search = NewSearchDocument(f.Title, pagePath, f.doc)
+
+	ctx.TOC = NewTOC(f.doc, p.Config.TOC.merge(f.TOC))
+
+	if p.Config.BaseURL != "" {
+		// pages have a <base> tag, anchors must include the page link
+		setTOCLinks(ctx.TOC, ctx.Link)
+	} else {
+		setTOCLinks(ctx.TOC, "")
+	}
+
 	return ctx, nil
 }
 
+// Returns the hash of the page source. Generated pages have no source file, their markdown is hashed instead.
+func (f *File) sourceHash() string {
+	if f.hash == "" {
+		f.hash = hashBytes(f.content)
+	}
+
+	return f.hash
+}
+
+// Renders a parsed markdown document
+func renderMarkdown(rnd blackfriday.Renderer, doc *blackfriday.Node) []byte {
+	buff := bytes.NewBuffer(make([]byte, 0))
+
+	rnd.RenderHeader(buff, doc)
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		return rnd.RenderNode(buff, node, entering)
+	})
+	rnd.RenderFooter(buff, doc)
+
+	return buff.Bytes()
+}
+
 func (f *File) MenuItem() *MenuItem {
 	return &MenuItem{
 		Name:  f.Name,
 		Title: f.Title,
 		Link:  f.Path,
+		// paths of language pages are normalized, so they are linked from the site root
+		pagePath: f.lang != nil,
 	}
 }
 
@@ -0,0 +1,137 @@
+package zmdocs
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v2"
+)
+
+var frontMatterDelimiter = []byte("---")
+
+// Page properties that can be overridden from the front matter block of a source file
+type FrontMatter struct {
+	Name         *string    `yaml:"name"`
+	Path         *string    `yaml:"path"`
+	Title        *string    `yaml:"title"`
+	Template     *string    `yaml:"template"`
+	AddToMenu    *bool      `yaml:"addToMenu"`
+	MenuGroup    *string    `yaml:"menuGroup"`
+	EditOnGithub *bool      `yaml:"editOnGithub"`
+	TOC          *TOCConfig `yaml:"toc"`
+	Sitemap      *bool      `yaml:"sitemap"`
+}
+
+// Keys handled by FrontMatter, these are not exposed in File.Params
+var frontMatterKeys = map[string]bool{
+	"name":         true,
+	"path":         true,
+	"title":        true,
+	"template":     true,
+	"addToMenu":    true,
+	"menuGroup":    true,
+	"editOnGithub": true,
+	"toc":          true,
+	"sitemap":      true,
+}
+
+// Splits an optional YAML front matter block from the top of the provided source.
+// Returns the raw front matter (nil if there is none) and the remaining markdown body.
+func SplitFrontMatter(src []byte) ([]byte, []byte) {
+	if !bytes.HasPrefix(src, frontMatterDelimiter) {
+		return nil, src
+	}
+
+	firstLineEnd := bytes.IndexByte(src, '\n')
+
+	if firstLineEnd == -1 || len(bytes.TrimSpace(src[:firstLineEnd])) != len(frontMatterDelimiter) {
+		return nil, src
+	}
+
+	rest := src[firstLineEnd+1:]
+	offset := 0
+
+	for offset <= len(rest) {
+		lineEnd := bytes.IndexByte(rest[offset:], '\n')
+		var line []byte
+
+		if lineEnd == -1 {
+			line = rest[offset:]
+		} else {
+			line = rest[offset : offset+lineEnd]
+		}
+
+		if bytes.Equal(bytes.TrimRight(line, " \t\r"), frontMatterDelimiter) {
+			if lineEnd == -1 {
+				return rest[:offset], []byte{}
+			}
+
+			return rest[:offset], rest[offset+lineEnd+1:]
+		}
+
+		if lineEnd == -1 {
+			break
+		}
+
+		offset += lineEnd + 1
+	}
+
+	// no closing delimiter, treat the whole thing as markdown
+	return nil, src
+}
+
+// Parses front matter and merges it over the file's page properties.
+// Keys that don't map to a page property are stored in f.Params.
+func (f *File) applyFrontMatter(data []byte) error {
+	var fm FrontMatter
+	params := make(map[string]interface{})
+
+	if err := yaml.Unmarshal(data, &fm); err != nil {
+		return fmt.Errorf("unable to parse front matter: %s", err.Error())
+	} else if err := yaml.Unmarshal(data, &params); err != nil {
+		return fmt.Errorf("unable to parse front matter: %s", err.Error())
+	}
+
+	if fm.Name != nil {
+		f.Name = *fm.Name
+	}
+
+	if fm.Path != nil {
+		f.Path = *fm.Path
+	}
+
+	if fm.Title != nil {
+		f.Title = *fm.Title
+	}
+
+	if fm.Template != nil {
+		f.Template = *fm.Template
+	}
+
+	if fm.AddToMenu != nil {
+		f.AddToMenu = *fm.AddToMenu
+	}
+
+	if fm.MenuGroup != nil {
+		f.MenuGroup = *fm.MenuGroup
+	}
+
+	if fm.EditOnGithub != nil {
+		f.EditOnGithub = *fm.EditOnGithub
+	}
+
+	if fm.TOC != nil {
+		f.TOC = fm.TOC
+	}
+
+	if fm.Sitemap != nil {
+		f.NoSitemap = !*fm.Sitemap
+	}
+
+	for k, v := range params {
+		if !frontMatterKeys[k] {
+			f.Params[k] = v
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package zmdocs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const globStar = "**"
+
+// Returns all files matching the provided glob pattern, sorted by path.
+// In addition to the syntax supported by filepath.Match, a `**` path segment matches
+// zero or more directories, e.g. `docs/**/*.md`.
+func Glob(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+
+	if !strings.Contains(pattern, globStar) {
+		matches, err := filepath.Glob(pattern)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(matches)
+
+		return matches, nil
+	}
+
+	// validate all segments before walking the tree
+	for _, seg := range splitPath(pattern) {
+		if seg != globStar {
+			if _, err := filepath.Match(seg, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	root := globBaseDir(pattern)
+	matches := make([]string, 0)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		if MatchGlob(pattern, path) {
+			matches = append(matches, path)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+
+	return matches, nil
+}
+
+// Reports whether the path matches the glob pattern. Supports `**` segments, see Glob.
+func MatchGlob(pattern, path string) bool {
+	return matchSegments(splitPath(filepath.Clean(pattern)), splitPath(filepath.Clean(path)))
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == globStar {
+			// collapse consecutive stars
+			for len(pattern) > 1 && pattern[1] == globStar {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 1 {
+				return true
+			}
+
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+
+		if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		path = path[1:]
+	}
+
+	return len(path) == 0
+}
+
+// Returns the longest leading directory of the pattern that has no glob meta characters
+func globBaseDir(pattern string) string {
+	segs := splitPath(pattern)
+	base := make([]string, 0, len(segs))
+
+	for _, seg := range segs[:len(segs)-1] {
+		if seg == globStar || strings.ContainsAny(seg, `*?[\`) {
+			break
+		}
+
+		base = append(base, seg)
+	}
+
+	dir := strings.Join(base, string(filepath.Separator))
+
+	if filepath.IsAbs(pattern) && !filepath.IsAbs(dir) {
+		dir = string(filepath.Separator) + dir
+	}
+
+	if dir == "" {
+		return "."
+	}
+
+	return dir
+}
+
+func splitPath(path string) []string {
+	segs := strings.Split(filepath.ToSlash(path), "/")
+	out := make([]string, 0, len(segs))
+
+	for _, seg := range segs {
+		if seg != "" {
+			out = append(out, seg)
+		}
+	}
+
+	return out
+}
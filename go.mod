@@ -3,6 +3,7 @@ module github.com/zyra/zmdocs
 go 1.12
 
 require (
+	github.com/alecthomas/chroma v0.10.0
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/gorilla/websocket v1.4.1
 	github.com/russross/blackfriday v2.0.0+incompatible
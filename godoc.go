@@ -0,0 +1,579 @@
+package zmdocs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Go package that API reference pages are generated from
+type GoPackage struct {
+	Name       string // Package name
+	ImportPath string // Full import path, derived from the go.mod file in the root directory if there is one
+	Dir        string // Absolute path of the package directory
+	RelDir     string // Path of the package directory relative to the scanned directory, empty for the top level package
+
+	doc      *doc.Package
+	fset     *token.FileSet
+	examples map[string][]*doc.Example
+}
+
+var goModuleRegex = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+
+// Finds all Go packages under the source directory and returns one file per package.
+// Each file contains the package API reference as markdown.
+func (p *Parser) loadGoPackages() error {
+	modulePath := goModulePath(p.Config.RootDir)
+
+	for i, src := range p.Config.GoPackages {
+		dir := filepath.Join(p.Config.RootDir, src.Dir)
+
+		log.WithFields(logrus.Fields{
+			"dir": dir,
+		}).Debug("processing go packages")
+
+		pkgs, err := FindGoPackages(dir, modulePath, p.Config.RootDir, p.goPackageExcludes(src))
+
+		if err != nil {
+			return fmt.Errorf("unable to process go packages #%d: %s", i, err.Error())
+		}
+
+		log.Debugf("found %d go packages", len(pkgs))
+
+		for _, pkg := range pkgs {
+			if file, err := GetFileForGoPackage(src, pkg); err != nil {
+				return fmt.Errorf("unable to process go package %s: \n\t%s", pkg.ImportPath, err.Error())
+			} else {
+				p.Files = append(p.Files, file)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) goPackageExcludes(src *GoPackagesSource) []string {
+	exclude := make([]string, 0, len(src.Exclude)+1)
+
+	for _, ex := range src.Exclude {
+		exclude = append(exclude, filepath.Join(p.Config.RootDir, ex))
+	}
+
+	if p.Config.OutDir != "" {
+		exclude = append(exclude, p.Config.OutDir)
+	}
+
+	return exclude
+}
+
+// Returns the module path declared in the go.mod file of the provided directory, if any
+func goModulePath(dir string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+
+	if err != nil {
+		return ""
+	}
+
+	if m := goModuleRegex.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+
+	return ""
+}
+
+// Finds and parses all Go packages in the directory tree. Hidden, `_` prefixed, `testdata` and `vendor`
+// directories are skipped, as well as any directory matching one of the exclude globs.
+func FindGoPackages(dir, modulePath, rootDir string, exclude []string) ([]*GoPackage, error) {
+	pkgs := make([]*GoPackage, 0)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		name := info.Name()
+
+		if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+			return filepath.SkipDir
+		}
+
+		for _, ex := range exclude {
+			if MatchGlob(ex, path) {
+				return filepath.SkipDir
+			}
+		}
+
+		pkg, err := ParseGoPackage(path)
+
+		if err != nil {
+			return err
+		} else if pkg == nil {
+			return nil
+		}
+
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != "." {
+			pkg.RelDir = filepath.ToSlash(rel)
+		}
+
+		importRel := ""
+
+		if rel, err := filepath.Rel(rootDir, path); err == nil && rel != "." {
+			importRel = filepath.ToSlash(rel)
+		}
+
+		switch {
+		case modulePath != "" && importRel != "":
+			pkg.ImportPath = modulePath + "/" + importRel
+		case modulePath != "":
+			pkg.ImportPath = modulePath
+		case importRel != "":
+			pkg.ImportPath = importRel
+		default:
+			pkg.ImportPath = pkg.Name
+		}
+
+		pkg.doc.ImportPath = pkg.ImportPath
+		pkgs = append(pkgs, pkg)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgs, nil
+}
+
+// Parses the Go package in the provided directory. Files are selected like `go build` does, so build
+// constraints apply. Returns nil if the directory has no (non-main) Go package, and an error if it has
+// several packages.
+func ParseGoPackage(dir string) (*GoPackage, error) {
+	bp, err := build.ImportDir(dir, 0)
+
+	if _, ok := err.(*build.NoGoError); ok {
+		return nil, nil
+	} else if mpe, ok := err.(*build.MultiplePackageError); ok {
+		return nil, fmt.Errorf("found packages %s (%s) and %s (%s) in %s", mpe.Packages[0], mpe.Files[0], mpe.Packages[1], mpe.Files[1], dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read go package in %s: %s", dir, err.Error())
+	}
+
+	if bp.Name == "main" || len(bp.GoFiles)+len(bp.CgoFiles) == 0 {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+
+	parseFiles := func(names ...[]string) ([]*ast.File, error) {
+		files := make([]*ast.File, 0)
+
+		for _, group := range names {
+			for _, name := range group {
+				f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse go files in %s: %s", dir, err.Error())
+				}
+
+				files = append(files, f)
+			}
+		}
+
+		return files, nil
+	}
+
+	pkgFiles, err := parseFiles(bp.GoFiles, bp.CgoFiles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	testFiles, err := parseFiles(bp.TestGoFiles, bp.XTestGoFiles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	astPkg := &ast.Package{
+		Name:  bp.Name,
+		Files: make(map[string]*ast.File),
+	}
+
+	for _, f := range pkgFiles {
+		astPkg.Files[fset.Position(f.Package).Filename] = f
+	}
+
+	pkg := GoPackage{
+		Name:     astPkg.Name,
+		Dir:      dir,
+		fset:     fset,
+		doc:      doc.New(astPkg, "", 0),
+		examples: make(map[string][]*doc.Example),
+	}
+
+	for _, ex := range doc.Examples(testFiles...) {
+		target, _ := splitExampleName(ex.Name)
+		pkg.examples[target] = append(pkg.examples[target], ex)
+	}
+
+	return &pkg, nil
+}
+
+// Splits an example name into the documented symbol and the example suffix,
+// e.g. "T_Method_second" -> ("T.Method", "second")
+func splitExampleName(name string) (string, string) {
+	suffix := ""
+
+	if i := strings.LastIndex(name, "_"); i != -1 && i+1 < len(name) && unicode.IsLower(rune(name[i+1])) {
+		suffix = name[i+1:]
+		name = name[:i]
+	}
+
+	return strings.Replace(name, "_", ".", 1), suffix
+}
+
+// Constructs a File instance for a Go package
+func GetFileForGoPackage(src *GoPackagesSource, pkg *GoPackage) (*File, error) {
+	pm := &PatternMatch{
+		Path: pkg.Dir,
+		Groups: map[string]string{
+			"name":       pkg.Name,
+			"importPath": pkg.ImportPath,
+			"relDir":     pkg.RelDir,
+		},
+	}
+
+	file := File{
+		Params: map[string]interface{}{
+			"package":    pkg.Name,
+			"importPath": pkg.ImportPath,
+		},
+	}
+	file.AddToMenu = src.AddToMenu
+	file.Template = src.Template
+	// the source is the package directory, there is no file to edit
+	file.SourceFile = pkg.Dir
+
+	nameTmpl := src.Name
+	pathTmpl := src.Path
+	titleTmpl := src.Title
+
+	if nameTmpl == "" {
+		nameTmpl = "{{ .Groups.importPath }}"
+	}
+
+	if pathTmpl == "" {
+		pathTmpl = "api/{{ .Groups.relDir }}"
+	}
+
+	if titleTmpl == "" {
+		titleTmpl = "Package {{ .Groups.name }}"
+	}
+
+	var err error
+
+	if file.Name, err = stringFromTemplate(nameTmpl, pm); err != nil {
+		return nil, fmt.Errorf("unable to parse name template: \n\t%s", err.Error())
+	} else if file.Path, err = stringFromTemplate(pathTmpl, pm); err != nil {
+		return nil, fmt.Errorf("unable to parse path template: \n\t%s", err.Error())
+	} else if file.Title, err = stringFromTemplate(titleTmpl, pm); err != nil {
+		return nil, fmt.Errorf("unable to parse title template: \n\t%s", err.Error())
+	} else if file.MenuGroup, err = stringFromTemplate(src.MenuGroup, pm); err != nil {
+		return nil, fmt.Errorf("unable to parse menu group template: \n\t%s", err.Error())
+	}
+
+	file.content = pkg.Markdown(file.Title)
+	file.loaded = true
+
+	return &file, nil
+}
+
+// Returns the API reference of the package as markdown.
+// Every symbol heading has a stable anchor: `#Func`, `#Type`, `#Type.Method`, `#pkg-constants` and `#pkg-variables`.
+func (pkg *GoPackage) Markdown(title string) []byte {
+	d := pkg.doc
+	w := bytes.NewBuffer(make([]byte, 0))
+
+	fmt.Fprintf(w, "# %s {#pkg-overview}\n\n", title)
+	fmt.Fprintf(w, "```go\nimport \"%s\"\n```\n\n", d.ImportPath)
+	w.WriteString(commentToMarkdown(d.Doc))
+	pkg.writeExamples(w, "")
+
+	w.WriteString("## Index {#pkg-index}\n\n")
+
+	if len(d.Consts) > 0 {
+		w.WriteString("- [Constants](#pkg-constants)\n")
+	}
+
+	if len(d.Vars) > 0 {
+		w.WriteString("- [Variables](#pkg-variables)\n")
+	}
+
+	for _, f := range d.Funcs {
+		fmt.Fprintf(w, "- [%s](#%s)\n", escapeMarkdown(pkg.nodeString(f.Decl)), f.Name)
+	}
+
+	for _, t := range d.Types {
+		fmt.Fprintf(w, "- [type %s](#%s)\n", t.Name, t.Name)
+
+		for _, f := range t.Funcs {
+			fmt.Fprintf(w, "  - [%s](#%s)\n", escapeMarkdown(pkg.nodeString(f.Decl)), f.Name)
+		}
+
+		for _, m := range t.Methods {
+			fmt.Fprintf(w, "  - [%s](#%s.%s)\n", escapeMarkdown(pkg.nodeString(m.Decl)), t.Name, m.Name)
+		}
+	}
+
+	w.WriteString("\n")
+
+	if len(d.Consts) > 0 {
+		w.WriteString("## Constants {#pkg-constants}\n\n")
+		pkg.writeValues(w, d.Consts)
+	}
+
+	if len(d.Vars) > 0 {
+		w.WriteString("## Variables {#pkg-variables}\n\n")
+		pkg.writeValues(w, d.Vars)
+	}
+
+	if len(d.Funcs) > 0 {
+		w.WriteString("## Functions {#pkg-functions}\n\n")
+
+		for _, f := range d.Funcs {
+			pkg.writeFunc(w, "###", f.Name, f)
+		}
+	}
+
+	if len(d.Types) > 0 {
+		w.WriteString("## Types {#pkg-types}\n\n")
+
+		for _, t := range d.Types {
+			fmt.Fprintf(w, "### type %s {#%s}\n\n", t.Name, t.Name)
+			pkg.writeCode(w, t.Decl)
+			w.WriteString(commentToMarkdown(t.Doc))
+			pkg.writeExamples(w, t.Name)
+			pkg.writeValues(w, t.Consts)
+			pkg.writeValues(w, t.Vars)
+
+			for _, f := range t.Funcs {
+				pkg.writeFunc(w, "####", f.Name, f)
+			}
+
+			for _, m := range t.Methods {
+				pkg.writeFunc(w, "####", t.Name+"."+m.Name, m)
+			}
+		}
+	}
+
+	return w.Bytes()
+}
+
+func (pkg *GoPackage) writeValues(w *bytes.Buffer, values []*doc.Value) {
+	for _, v := range values {
+		pkg.writeCode(w, v.Decl)
+		w.WriteString(commentToMarkdown(v.Doc))
+	}
+}
+
+func (pkg *GoPackage) writeFunc(w *bytes.Buffer, level, anchor string, f *doc.Func) {
+	name := f.Name
+
+	if f.Recv != "" {
+		name = fmt.Sprintf("(%s) %s", f.Recv, f.Name)
+	}
+
+	fmt.Fprintf(w, "%s func %s {#%s}\n\n", level, escapeMarkdown(name), anchor)
+	pkg.writeCode(w, f.Decl)
+	w.WriteString(commentToMarkdown(f.Doc))
+	pkg.writeExamples(w, anchor)
+}
+
+func (pkg *GoPackage) writeExamples(w *bytes.Buffer, target string) {
+	examples := pkg.examples[target]
+
+	sort.Slice(examples, func(i, j int) bool {
+		return examples[i].Name < examples[j].Name
+	})
+
+	for _, ex := range examples {
+		anchor := "example-" + ex.Name
+
+		if ex.Name == "" {
+			anchor = "example-package"
+		}
+
+		_, suffix := splitExampleName(ex.Name)
+		heading := "Example"
+
+		if suffix != "" {
+			heading = fmt.Sprintf("Example (%s)", Humanize(suffix))
+		}
+
+		fmt.Fprintf(w, "##### %s {#%s}\n\n", heading, anchor)
+		w.WriteString(commentToMarkdown(ex.Doc))
+
+		code := pkg.nodeString(ex.Code)
+
+		if block, ok := ex.Code.(*ast.BlockStmt); ok && block != nil {
+			code = strings.TrimSuffix(strings.TrimPrefix(code, "{"), "}")
+			code = unindent(strings.Trim(code, "\n"))
+		}
+
+		fmt.Fprintf(w, "```go\n%s\n```\n\n", code)
+
+		if ex.Output != "" {
+			fmt.Fprintf(w, "Output:\n\n```\n%s```\n\n", ex.Output)
+		}
+	}
+}
+
+// Writes the declaration of a symbol as a Go code block, function bodies are omitted
+func (pkg *GoPackage) writeCode(w *bytes.Buffer, decl ast.Decl) {
+	fmt.Fprintf(w, "```go\n%s\n```\n\n", pkg.nodeString(decl))
+}
+
+// Prints an AST node, function bodies and doc comments of declarations are omitted
+func (pkg *GoPackage) nodeString(node ast.Node) string {
+	if fd, ok := node.(*ast.FuncDecl); ok {
+		sig := *fd
+		sig.Body = nil
+		sig.Doc = nil
+		node = &sig
+	} else if gd, ok := node.(*ast.GenDecl); ok {
+		decl := *gd
+		decl.Doc = nil
+		node = &decl
+	}
+
+	buff := bytes.NewBuffer(make([]byte, 0))
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 4}
+
+	if err := cfg.Fprint(buff, pkg.fset, node); err != nil {
+		return ""
+	}
+
+	return buff.String()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// Converts a Go doc comment to markdown. Indented blocks become code blocks, all other text is escaped.
+func commentToMarkdown(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0))
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	inCode := false
+	code := make([]string, 0)
+
+	flushCode := func() {
+		for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+			code = code[:len(code)-1]
+		}
+
+		fmt.Fprintf(out, "```\n%s\n```\n\n", unindent(strings.Join(code, "\n")))
+		code = code[:0]
+		inCode = false
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+
+		if inCode {
+			if indented || strings.TrimSpace(line) == "" {
+				code = append(code, line)
+				continue
+			}
+
+			flushCode()
+		}
+
+		if indented {
+			inCode = true
+			code = append(code, line)
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			out.WriteString("\n")
+		} else {
+			out.WriteString(escapeMarkdown(line))
+			out.WriteString("\n")
+		}
+	}
+
+	if inCode {
+		flushCode()
+	}
+
+	out.WriteString("\n")
+
+	return out.String()
+}
+
+// Removes the common leading whitespace of all non-empty lines
+func unindent(s string) string {
+	lines := strings.Split(s, "\n")
+	prefix := ""
+	first := true
+
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		if first {
+			prefix = indent
+			first = false
+			continue
+		}
+
+		for !strings.HasPrefix(line, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+
+	return strings.Join(lines, "\n")
+}
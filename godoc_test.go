@@ -0,0 +1,104 @@
+package zmdocs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGoPackage(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "zmdocs")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	writePackage := func(name string, files map[string]string) string {
+		t.Helper()
+
+		dir := filepath.Join(tmp, name)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		for fileName, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, fileName), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return dir
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		pkg     string
+		example string
+		wantErr string
+	}{
+		{
+			name: "external tests",
+			files: map[string]string{
+				"foo.go":      "// Package foo does things\npackage foo\n\nfunc Bar() {}\n",
+				"foo_test.go": "package foo_test\n\nfunc ExampleBar() {}\n",
+			},
+			pkg:     "foo",
+			example: "Bar",
+		},
+		{
+			name: "build constraints",
+			files: map[string]string{
+				"foo.go": "package foo\n",
+				"gen.go": "// +build ignore\n\npackage main\n",
+			},
+			pkg: "foo",
+		},
+		{
+			name: "several packages",
+			files: map[string]string{
+				"foo.go": "package foo\n",
+				"bar.go": "package bar\n",
+			},
+			wantErr: "found packages",
+		},
+		{
+			name:  "main package",
+			files: map[string]string{"main.go": "package main\n\nfunc main() {}\n"},
+		},
+	}
+
+	for i, tt := range tests {
+		pkg, err := ParseGoPackage(writePackage(string(rune('a'+i)), tt.files))
+
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+
+		if tt.pkg == "" {
+			if pkg != nil {
+				t.Errorf("%s: expected no package, got %s", tt.name, pkg.Name)
+			}
+			continue
+		}
+
+		if pkg == nil || pkg.Name != tt.pkg {
+			t.Errorf("%s: expected package %s, got %+v", tt.name, tt.pkg, pkg)
+		} else if tt.example != "" && len(pkg.examples[tt.example]) == 0 {
+			t.Errorf("%s: expected an example for %s", tt.name, tt.example)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package zmdocs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/russross/blackfriday"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults used when the highlight config omits a value
+const (
+	DefaultHighlightStyle      = "github"
+	DefaultHighlightStylesheet = "css/highlight.css"
+)
+
+// Matches fence lines with attributes after the language, e.g. "```go {3-5}"
+var fenceAttrsRegex = regexp.MustCompile("(?m)^( {0,3})(`{3,}|~{3,})[ \t]*([^\\s{`]+)[ \t]*\\{([^}\n]*)\\}[ \t]*$")
+
+// Syntax highlighting configuration
+type HighlightConfig struct {
+	Disabled    bool   `yaml:"disabled"`    // Disable build time syntax highlighting
+	Style       string `yaml:"style"`       // Chroma style name, defaults to "github"
+	LineNumbers bool   `yaml:"lineNumbers"` // Show line numbers on all code blocks, can be toggled per block with `linenos` / `nolinenos`
+	Classes     bool   `yaml:"classes"`     // Use CSS classes instead of inline styles. The stylesheet is written to Stylesheet.
+	Stylesheet  string `yaml:"stylesheet"`  // Path of the generated stylesheet relative to the output directory, defaults to "css/highlight.css"
+}
+
+func (c *HighlightConfig) style() *chroma.Style {
+	name := DefaultHighlightStyle
+
+	if c != nil && c.Style != "" {
+		name = c.Style
+	}
+
+	return styles.Get(name)
+}
+
+// Returns the stylesheet link to add to pages, empty if highlighting uses inline styles
+func (c *HighlightConfig) StylesheetLink() string {
+	if c == nil || c.Disabled || !c.Classes {
+		return ""
+	}
+
+	if c.Stylesheet == "" {
+		return DefaultHighlightStylesheet
+	}
+
+	return filepath.ToSlash(c.Stylesheet)
+}
+
+// Writes the CSS of the configured style to w
+func (c *HighlightConfig) WriteCSS(w io.Writer) error {
+	return html.New(html.WithClasses(true)).WriteCSS(w, c.style())
+}
+
+// Writes the highlight stylesheet to the output directory. Returns the written file path, empty if there is no stylesheet.
+func (c *HighlightConfig) writeStylesheet(fs OutputFS, outDir string) (string, error) {
+	link := c.StylesheetLink()
+
+	if link == "" {
+		return "", nil
+	}
+
+	path := filepath.Join(outDir, filepath.FromSlash(link))
+	buff := bytes.NewBuffer(make([]byte, 0))
+
+	if err := c.WriteCSS(buff); err != nil {
+		return "", err
+	}
+
+	return path, fs.WriteFile(path, buff.Bytes(), time.Time{})
+}
+
+// Blackfriday renderer that highlights fenced code blocks, all other nodes are rendered by the HTML renderer
+type HighlightRenderer struct {
+	*blackfriday.HTMLRenderer
+	Config *HighlightConfig
+}
+
+// Returns the markdown renderer for the provided config
+func newMarkdownRenderer(c *ParserConfig) blackfriday.Renderer {
+	rnd := blackfriday.NewHTMLRenderer(blackFridayRndOpts)
+
+	if c.Highlight != nil && c.Highlight.Disabled {
+		return rnd
+	}
+
+	return &HighlightRenderer{
+		HTMLRenderer: rnd,
+		Config:       c.Highlight,
+	}
+}
+
+func (r *HighlightRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	if node.Type == blackfriday.CodeBlock && node.IsFenced {
+		if err := r.highlight(w, node); err == nil {
+			return blackfriday.GoToNext
+		} else if err != errNoLexer {
+			log.Warnf("unable to highlight code block: %s", err.Error())
+		}
+	}
+
+	return r.HTMLRenderer.RenderNode(w, node, entering)
+}
+
+var errNoLexer = errors.New("no lexer")
+
+func (r *HighlightRenderer) highlight(w io.Writer, node *blackfriday.Node) error {
+	lang, attrs := parseFenceInfo(string(node.Info))
+
+	if lang == "" {
+		return errNoLexer
+	}
+
+	lexer := lexers.Get(lang)
+
+	if lexer == nil {
+		return errNoLexer
+	}
+
+	lineNumbers := r.Config != nil && r.Config.LineNumbers
+	ranges := make([][2]int, 0)
+
+	for _, attr := range attrs {
+		switch attr {
+		case "linenos":
+			lineNumbers = true
+		case "nolinenos":
+			lineNumbers = false
+		default:
+			if rng, ok := parseLineRange(attr); ok {
+				ranges = append(ranges, rng)
+			}
+		}
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(node.Literal))
+
+	if err != nil {
+		return err
+	}
+
+	formatter := html.New(
+		html.WithClasses(r.Config != nil && r.Config.Classes),
+		html.WithLineNumbers(lineNumbers),
+		html.HighlightLines(ranges),
+		html.TabWidth(4),
+	)
+
+	buff := bytes.NewBuffer(make([]byte, 0))
+
+	if err := formatter.Format(buff, r.Config.style(), iterator); err != nil {
+		return err
+	}
+
+	_, err = w.Write(buff.Bytes())
+
+	return err
+}
+
+// Splits a fence info string into the language and its attributes, e.g. "go 3-5,8 linenos" -> ("go", ["3-5", "8", "linenos"])
+func parseFenceInfo(info string) (string, []string) {
+	fields := strings.FieldsFunc(info, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	return fields[0], fields[1:]
+}
+
+// Parses "3" or "3-5" into an inclusive line range
+func parseLineRange(s string) ([2]int, bool) {
+	parts := strings.SplitN(s, "-", 2)
+	start, err := strconv.Atoi(parts[0])
+
+	if err != nil {
+		return [2]int{}, false
+	}
+
+	end := start
+
+	if len(parts) == 2 {
+		if end, err = strconv.Atoi(parts[1]); err != nil {
+			return [2]int{}, false
+		}
+	}
+
+	return [2]int{start, end}, true
+}
+
+// Rewrites fence lines with attributes (e.g. "```go {3-5}") to the "```{go 3-5}" form that blackfriday can parse
+func normalizeFenceAttributes(src []byte) []byte {
+	return fenceAttrsRegex.ReplaceAll(src, []byte("$1$2{$3 $4}"))
+}
+
+// Writes the highlight stylesheet once the pages are rendered
+type highlightPlugin struct {
+	BasePlugin
+}
+
+func (highlightPlugin) Name() string {
+	return "highlight"
+}
+
+func (highlightPlugin) AfterRender(s *Site) error {
+	if s.r.Highlight == nil {
+		return nil
+	}
+
+	if path, err := s.r.Highlight.writeStylesheet(s.FS, s.OutDir); err != nil {
+		return fmt.Errorf("unable to write highlight stylesheet: %s", err.Error())
+	} else if path != "" {
+		s.r.track(path)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package zmdocs
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Text directions of a language
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+// Language the docs are published in. Every language is rendered under its own URL prefix.
+// The first configured language is the default language.
+type Language struct {
+	Code      string            `yaml:"code"`      // Language code, used as the URL prefix and the html `lang` attribute
+	Name      string            `yaml:"name"`      // Name shown in the language switcher, defaults to the code
+	Direction string            `yaml:"direction"` // Text direction, "ltr" (default) or "rtl"
+	Root      string            `yaml:"root"`      // Content root relative to the root directory. Page sources of this language are resolved from here.
+	Strings   map[string]string `yaml:"strings"`   // Translations of menu titles and template strings, keyed by the untranslated string
+}
+
+// Same page in another language, for the language switcher
+type Translation struct {
+	Code    string
+	Name    string
+	Link    string
+	Current bool
+}
+
+// Returns the translation of s, or s if there is none
+func (l *Language) T(s string) string {
+	if l != nil {
+		if t, ok := l.Strings[s]; ok && t != "" {
+			return t
+		}
+	}
+
+	return s
+}
+
+// Returns the text direction, defaults to "ltr"
+func (l *Language) Dir() string {
+	if l == nil || l.Direction == "" {
+		return DirectionLTR
+	}
+
+	return l.Direction
+}
+
+// Returns the name shown in the language switcher
+func (l *Language) DisplayName() string {
+	if l.Name != "" {
+		return l.Name
+	}
+
+	return l.Code
+}
+
+// Returns the default language, nil if no languages are configured
+func (c *ParserConfig) DefaultLanguage() *Language {
+	if len(c.Languages) == 0 {
+		return nil
+	}
+
+	return c.Languages[0]
+}
+
+// Returns the page path prefixed with the language code
+func languagePath(lang *Language, pagePath string) string {
+	return normalizePagePath(lang.Code + "/" + normalizePagePath(pagePath))
+}
+
+// Returns a copy of the menu with translated titles and page links prefixed with the language code
+func localizeMenu(items []*MenuItem, lang *Language) []*MenuItem {
+	out := make([]*MenuItem, len(items))
+
+	for i, it := range items {
+		mit := *it
+		mit.Title = lang.T(it.Title)
+
+		if it.Items != nil {
+			mit.Items = localizeMenu(it.Items, lang)
+		}
+
+		if !it.Group {
+			if u, err := url.Parse(it.Link); err == nil && u.Scheme == "" && u.Host == "" && !strings.HasPrefix(it.Link, "#") {
+				mit.Link = languagePath(lang, it.Link)
+				// the language prefix makes the link a page path, whether or not it started at the site root
+				mit.pagePath = u.RawQuery == "" && u.Fragment == ""
+			}
+		}
+
+		out[i] = &mit
+	}
+
+	return out
+}
+
+// Loads the pages of every language from its content root. Pages are matched across languages by their source
+// path relative to the content root. Pages that have no translation fall back to the default language, so every
+// language has at least the pages of the default language. Go packages and OpenAPI specs are only loaded for the
+// default language.
+func (p *Parser) loadLanguageFiles() error {
+	def := p.Config.DefaultLanguage()
+	langFiles := make(map[*Language][]*File)
+
+	for _, lang := range p.Config.Languages {
+		root := filepath.Join(p.Config.RootDir, lang.Root)
+		start := len(p.Files)
+
+		if err := p.loadStaticFiles(root); err != nil {
+			return err
+		}
+
+		if err := p.loadGlobFiles(root, p.languageExcludes(lang)...); err != nil {
+			return err
+		}
+
+		for _, f := range p.Files[start:] {
+			f.lang = lang
+
+			if rel, err := filepath.Rel(root, f.SourceFile); err == nil {
+				f.translationKey = filepath.ToSlash(rel)
+			} else {
+				f.translationKey = f.SourceFile
+			}
+		}
+
+		if lang == def {
+			start = len(p.Files)
+
+			if err := p.loadGoPackages(); err != nil {
+				return err
+			}
+
+			if err := p.loadOpenAPISpecs(); err != nil {
+				return err
+			}
+
+			for _, f := range p.Files[start:] {
+				f.lang = lang
+				f.translationKey = "\x00" + normalizePagePath(f.Path)
+			}
+		}
+
+		for _, f := range p.Files {
+			if f.lang == lang {
+				langFiles[lang] = append(langFiles[lang], f)
+			}
+		}
+	}
+
+	for _, f := range p.Files {
+		if err := f.Load(); err != nil {
+			return fmt.Errorf("unable to load file: %s", err.Error())
+		}
+	}
+
+	files := langFiles[def]
+
+	for _, lang := range p.Config.Languages[1:] {
+		translated := make(map[string]*File)
+
+		for _, f := range langFiles[lang] {
+			translated[f.translationKey] = f
+		}
+
+		// keep the order of the default language so menus are consistent across languages
+		for _, df := range langFiles[def] {
+			if f, ok := translated[df.translationKey]; ok {
+				files = append(files, f)
+				delete(translated, df.translationKey)
+				continue
+			}
+
+			fb := *df
+			fb.lang = lang
+			fb.fallback = true
+			files = append(files, &fb)
+		}
+
+		for _, f := range langFiles[lang] {
+			if _, ok := translated[f.translationKey]; ok {
+				files = append(files, f)
+			}
+		}
+	}
+
+	for _, f := range files {
+		f.Path = languagePath(f.lang, f.Path)
+	}
+
+	p.Files = files
+
+	return nil
+}
+
+// Returns globs matching the content roots of other languages that are inside the content root of lang
+func (p *Parser) languageExcludes(lang *Language) []string {
+	root := filepath.Join(p.Config.RootDir, lang.Root)
+	exclude := make([]string, 0)
+
+	for _, other := range p.Config.Languages {
+		otherRoot := filepath.Join(p.Config.RootDir, other.Root)
+
+		if rel, err := filepath.Rel(root, otherRoot); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			exclude = append(exclude, filepath.Join(otherRoot, globStar))
+		}
+	}
+
+	return exclude
+}
+
+// Makes links written against the default language resolve to the pages of the language of files. This covers
+// links in pages that fall back to the default language, and page path links without the language prefix.
+func (p *Parser) addLanguageAliases(idx *linkIndex, files []*File) {
+	def := p.Config.DefaultLanguage()
+
+	if def == nil {
+		return
+	}
+
+	defSources := make(map[string]string)
+
+	for _, f := range p.Files {
+		if f.lang == def && f.SourceFile != "" {
+			defSources[f.translationKey] = filepath.Clean(f.SourceFile)
+		}
+	}
+
+	for _, f := range files {
+		if f.fallback && !strings.HasPrefix(f.translationKey, "\x00") {
+			// relative links inside the language root point to where the translation would be
+			src := filepath.Join(p.Config.RootDir, f.lang.Root, filepath.FromSlash(f.translationKey))
+
+			if _, exists := idx.sources[src]; !exists {
+				idx.sources[src] = f
+			}
+		}
+
+		if src, ok := defSources[f.translationKey]; ok {
+			if _, exists := idx.sources[src]; !exists {
+				idx.sources[src] = f
+			}
+		}
+
+		unprefixed := normalizePagePath(f.Path)
+
+		if unprefixed == f.lang.Code {
+			unprefixed = ""
+		} else {
+			unprefixed = strings.TrimPrefix(unprefixed, f.lang.Code+"/")
+		}
+
+		if _, exists := idx.paths[unprefixed]; !exists {
+			idx.paths[unprefixed] = f
+		}
+	}
+}
+
+// Sets the translations of every page to the pages with the same source in every language.
+// files and ctxs must have the same order.
+func setTranslations(files []*File, ctxs []*RenderContext) {
+	byKey := make(map[string][]int)
+
+	for i, f := range files {
+		if f.lang != nil {
+			byKey[f.translationKey] = append(byKey[f.translationKey], i)
+		}
+	}
+
+	for i, f := range files {
+		if f.lang == nil {
+			continue
+		}
+
+		for _, j := range byKey[f.translationKey] {
+			link := normalizePagePath(files[j].Path)
+
+			if link != "" {
+				link += "/"
+			}
+
+			ctxs[i].Translations = append(ctxs[i].Translations, &Translation{
+				Code:    files[j].lang.Code,
+				Name:    files[j].lang.DisplayName(),
+				Link:    ctxs[i].RootURL + link,
+				Current: i == j,
+			})
+		}
+	}
+}
+
+// Redirects the site root to the default language once the pages are rendered
+type languageRedirectPlugin struct {
+	BasePlugin
+}
+
+func (languageRedirectPlugin) Name() string {
+	return "language-redirect"
+}
+
+func (languageRedirectPlugin) AfterRender(s *Site) error {
+	if len(s.r.Languages) == 0 {
+		return nil
+	}
+
+	if path, err := writeRedirect(s.FS, s.OutDir, s.r.Languages[0].Code+"/"); err != nil {
+		return fmt.Errorf("unable to write language redirect: %s", err.Error())
+	} else {
+		s.r.track(path)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package zmdocs
+
+import (
+	"testing"
+)
+
+func TestLocalizedMenuLinks(t *testing.T) {
+	lang := &Language{Code: "ja"}
+	menu := localizeMenu([]*MenuItem{
+		{Title: "Home", Link: "/"},
+		{Title: "Guide", Link: "/guide/a"},
+		{Title: "Relative", Link: "guide/b"},
+		{Title: "Repo", Link: "https://example.com/repo"},
+	}, lang)
+
+	tests := []struct {
+		rootURL string
+		link    string
+		want    []string
+	}{
+		{rootURL: "../../../", link: "ja/guide/a", want: []string{"../../../ja/", "../../../ja/guide/a/", "../../../ja/guide/b/", "https://example.com/repo"}},
+		{rootURL: "https://ex.com/docs/", link: "ja", want: []string{"https://ex.com/docs/ja/", "https://ex.com/docs/ja/guide/a/", "https://ex.com/docs/ja/guide/b/", "https://example.com/repo"}},
+	}
+
+	for _, tt := range tests {
+		items := activeMenu(menu, tt.link, tt.rootURL)
+
+		for i, it := range items {
+			if it.Link != tt.want[i] {
+				t.Errorf("%s: expected %q to link to %q, got %q", tt.rootURL, it.Title, tt.want[i], it.Link)
+			}
+
+			if active := normalizePagePath(menu[i].Link) == tt.link; it.Active != active {
+				t.Errorf("%s: expected %q to be active: %t", tt.rootURL, it.Title, active)
+			}
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package zmdocs
+
+import (
+	"fmt"
+	"github.com/russross/blackfriday"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var htmlIDRegex = regexp.MustCompile(`\b(?:id|name)\s*=\s*["']([^"']+)["']`)
+
+// Source files that are used when a link points to a directory
+var directoryIndexFiles = []string{"README.md", "index.md"}
+
+// Broken internal link found while rendering a page
+type LinkError struct {
+	SourceFile string // File containing the link
+	Link       string // Link destination as written in the source
+	Reason     string
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("%s: broken link %q: %s", e.SourceFile, e.Link, e.Reason)
+}
+
+// Indexes loaded files by source path and page path so links between them can be resolved
+type linkIndex struct {
+	sources map[string]*File
+	paths   map[string]*File
+}
+
+func newLinkIndex(files []*File) *linkIndex {
+	idx := linkIndex{
+		sources: make(map[string]*File),
+		paths:   make(map[string]*File),
+	}
+
+	for _, f := range files {
+		if f.SourceFile != "" {
+			idx.sources[filepath.Clean(f.SourceFile)] = f
+		}
+
+		idx.paths[normalizePagePath(f.Path)] = f
+	}
+
+	return &idx
+}
+
+func normalizePagePath(p string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// Returns the file that produces the page for the provided source path, if any
+func (idx *linkIndex) source(target string) *File {
+	target = filepath.Clean(target)
+
+	if f, ok := idx.sources[target]; ok {
+		return f
+	}
+
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		for _, name := range directoryIndexFiles {
+			if f, ok := idx.sources[filepath.Join(target, name)]; ok {
+				return f
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the URL of page `to` as it should be written in page `from`.
+// When a base URL is configured pages have a <base> tag, so the page path is used as-is.
+// Otherwise a link relative to the directory of `from` is returned.
+func (p *Parser) pageURL(from, to *File) string {
+	toPath := normalizePagePath(to.Path)
+
+	if p.Config.BaseURL != "" {
+		if toPath == "" {
+			return p.Config.BaseURL
+		}
+
+		return to.Path
+	}
+
+	fromPath := normalizePagePath(from.Path)
+
+	if fromPath == toPath {
+		return "./"
+	}
+
+	return relativeURL(fromPath, toPath) + "/"
+}
+
+// Returns the URL of an output file (relative to the output directory) as it should be written in page `from`
+func (p *Parser) fileURL(from *File, file string) string {
+	if p.Config.BaseURL != "" {
+		return file
+	}
+
+	return relativeURL(normalizePagePath(from.Path), file)
+}
+
+// Returns the path of `to` relative to the directory `from`, both relative to the site root
+func relativeURL(from, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash("/"+from), filepath.FromSlash("/"+to))
+
+	if err != nil {
+		return "/" + to
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// Rewrites relative links in the parsed document of f to the pages built from their targets, and root-relative
+// links to page paths.
+// Local images are added to the assets of f and rewritten to their output path.
+// Returns an error for every link to a markdown source that produces no page, missing anchors and missing images.
+func (p *Parser) resolveLinks(f *File, idx *linkIndex) []error {
+	errs := make([]error, 0)
+
+	if f.doc == nil {
+		return errs
+	}
+
+	sourceDir := filepath.Dir(f.SourceFile)
+
+	if info, err := os.Stat(f.SourceFile); err == nil && info.IsDir() {
+		sourceDir = f.SourceFile
+	}
+
+	linkErr := func(dest, reason string) {
+		errs = append(errs, &LinkError{
+			SourceFile: f.SourceFile,
+			Link:       dest,
+			Reason:     reason,
+		})
+	}
+
+	f.doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering || (node.Type != blackfriday.Link && node.Type != blackfriday.Image) || node.NoteID != 0 {
+			return blackfriday.GoToNext
+		}
+
+		dest := string(node.LinkData.Destination)
+		u, err := url.Parse(dest)
+
+		if err != nil || u.Scheme != "" || u.Host != "" {
+			return blackfriday.GoToNext
+		}
+
+		if u.Path == "" {
+			// anchor on the same page
+			if u.Fragment != "" {
+				if !f.anchors[u.Fragment] {
+					linkErr(dest, "anchor not found")
+				}
+
+				if p.Config.BaseURL != "" {
+					node.LinkData.Destination = []byte(p.pageURL(f, f) + "#" + u.Fragment)
+				}
+			}
+
+			return blackfriday.GoToNext
+		}
+
+		var tf *File
+
+		if strings.HasPrefix(u.Path, "/") {
+			// root-relative links can only point at page paths, anything else is left as written
+			if node.Type == blackfriday.Link {
+				tf = idx.paths[normalizePagePath(u.Path)]
+			}
+
+			if tf == nil {
+				return blackfriday.GoToNext
+			}
+		} else {
+			target := filepath.Join(sourceDir, filepath.FromSlash(u.Path))
+
+			if node.Type == blackfriday.Image {
+				if _, err := os.Stat(target); err != nil {
+					linkErr(dest, "image not found")
+				} else if ap, err := assetPath(p.Config.RootDir, target); err != nil {
+					log.Warnf("%s: image %s is not copied: %s", f.SourceFile, dest, err.Error())
+				} else {
+					f.assets = append(f.assets, &Asset{SourceFile: target, Path: ap})
+					node.LinkData.Destination = []byte(p.fileURL(f, ap))
+				}
+
+				return blackfriday.GoToNext
+			}
+
+			tf = idx.source(target)
+
+			if tf == nil {
+				// page paths are relative to the path of the linking page
+				tf = idx.paths[normalizePagePath(path.Join(path.Dir(normalizePagePath(f.Path)), u.Path))]
+			}
+
+			if tf == nil {
+				// generated pages link to each other by their path from the site root
+				tf = idx.paths[normalizePagePath(u.Path)]
+			}
+
+			if tf == nil {
+				if ext := strings.ToLower(path.Ext(u.Path)); ext == ".md" || ext == ".markdown" {
+					linkErr(dest, "no page is generated from this file")
+				}
+
+				return blackfriday.GoToNext
+			}
+		}
+
+		newDest := p.pageURL(f, tf)
+
+		if u.RawQuery != "" {
+			newDest += "?" + u.RawQuery
+		}
+
+		if u.Fragment != "" {
+			if tf.anchors != nil && !tf.anchors[u.Fragment] {
+				linkErr(dest, "anchor not found")
+			}
+
+			newDest += "#" + u.Fragment
+		}
+
+		node.LinkData.Destination = []byte(newDest)
+
+		return blackfriday.GoToNext
+	})
+
+	return errs
+}
+
+// Returns the set of anchors defined in the document, from heading IDs and `id` attributes in raw HTML
+func documentAnchors(doc *blackfriday.Node) map[string]bool {
+	anchors := make(map[string]bool)
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+
+		switch node.Type {
+		case blackfriday.Heading:
+			if node.HeadingID != "" {
+				anchors[node.HeadingID] = true
+			}
+		case blackfriday.HTMLBlock, blackfriday.HTMLSpan:
+			for _, m := range htmlIDRegex.FindAllSubmatch(node.Literal, -1) {
+				anchors[string(m[1])] = true
+			}
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	return anchors
+}
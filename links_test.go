@@ -0,0 +1,88 @@
+package zmdocs
+
+import (
+	"github.com/russross/blackfriday"
+	"testing"
+)
+
+// Returns the destinations of the links in the parsed document of f
+func linkDestinations(f *File) []string {
+	links := make([]string, 0)
+
+	f.doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && node.Type == blackfriday.Link {
+			links = append(links, string(node.LinkData.Destination))
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	return links
+}
+
+func TestResolvePagePathLinks(t *testing.T) {
+	p := NewParser(&ParserConfig{})
+
+	newPage := func(pagePath, markdown string) *File {
+		f := NewGeneratedFile(BasePage{Path: pagePath}, "", []byte(markdown))
+
+		if err := f.Parse(p); err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	from := newPage("guide/intro", "[a](setup) [b](/setup) [c](/static/file.zip) [d](missing)")
+	files := []*File{
+		from,
+		newPage("guide/setup", "# Guide setup"),
+		newPage("setup", "# Setup"),
+	}
+
+	if errs := p.resolveLinks(from, newLinkIndex(files)); len(errs) > 0 {
+		t.Fatalf("unexpected link errors: %v", errs)
+	}
+
+	links := linkDestinations(from)
+	expected := []string{"../setup/", "../../setup/", "/static/file.zip", "missing"}
+
+	if len(links) != len(expected) {
+		t.Fatalf("expected %d links, got %v", len(expected), links)
+	}
+
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("link %d: expected %q, got %q", i, expected[i], link)
+		}
+	}
+}
+
+func TestResolveGeneratedPageLinks(t *testing.T) {
+	p := NewParser(&ParserConfig{})
+
+	newPage := func(pagePath, markdown string) *File {
+		f := NewGeneratedFile(BasePage{Path: pagePath}, "", []byte(markdown))
+
+		if err := f.Parse(p); err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	// links written by the OpenAPI generator use the path of the models page from the site root
+	from := newPage("pets/pets", "[Pet](pets/models#Pet)")
+	files := []*File{
+		from,
+		newPage("pets/models", "## Pet {#Pet}"),
+	}
+
+	if errs := p.resolveLinks(from, newLinkIndex(files)); len(errs) > 0 {
+		t.Fatalf("unexpected link errors: %v", errs)
+	}
+
+	if links := linkDestinations(from); len(links) != 1 || links[0] != "../models/#Pet" {
+		t.Errorf("expected the link to be rewritten to %q, got %v", "../models/#Pet", links)
+	}
+}
@@ -0,0 +1,143 @@
+package zmdocs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"time"
+)
+
+// Name of the build manifest written to the output directory
+const ManifestFileName = ".zmdocs-manifest.json"
+
+// Bumped when the output for the same inputs changes, so builds made with an older version are not reused
+const manifestVersion = 2
+
+// Records the inputs every output file was built from, so unchanged pages can be skipped on the next build
+type BuildManifest struct {
+	Version int                       `json:"version"`
+	Files   map[string]*ManifestEntry `json:"files"` // Keyed by output file path relative to the output directory
+}
+
+// Hashes of the inputs of an output file
+type ManifestEntry struct {
+	Source   string `json:"source"`   // Page source
+	Template string `json:"template"` // Template, base layout and partials
+	Config   string `json:"config"`   // Config, menus and the list of pages
+}
+
+// Reads the manifest from the output directory. Returns an empty manifest if there is none or it can't be used.
+func readManifest(fs OutputFS, outDir string) *BuildManifest {
+	m := BuildManifest{
+		Version: manifestVersion,
+		Files:   make(map[string]*ManifestEntry),
+	}
+
+	data, err := fs.ReadFile(filepath.Join(outDir, ManifestFileName))
+
+	if err != nil {
+		return &m
+	}
+
+	var prev BuildManifest
+
+	if err := json.Unmarshal(data, &prev); err != nil {
+		log.Warnf("ignoring invalid build manifest: %s", err.Error())
+		return &m
+	}
+
+	if prev.Version != manifestVersion || prev.Files == nil {
+		return &m
+	}
+
+	return &prev
+}
+
+// Writes the manifest to the output directory. Returns the written file path.
+func (m *BuildManifest) write(fs OutputFS, outDir string) (string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(outDir, ManifestFileName)
+
+	return dest, fs.WriteFile(dest, data, time.Time{})
+}
+
+// Reports whether outFile was built from the same inputs and still exists
+func (m *BuildManifest) unchanged(fs OutputFS, outDir, outFile string, inputs *ManifestEntry) bool {
+	key, err := filepath.Rel(outDir, outFile)
+
+	if err != nil {
+		return false
+	}
+
+	prev, ok := m.Files[filepath.ToSlash(key)]
+
+	if !ok || *prev != *inputs {
+		return false
+	}
+
+	_, err = fs.Stat(outFile)
+
+	return err == nil
+}
+
+// Records the inputs of outFile
+func (m *BuildManifest) set(outDir, outFile string, inputs *ManifestEntry) {
+	if key, err := filepath.Rel(outDir, outFile); err == nil {
+		m.Files[filepath.ToSlash(key)] = inputs
+	}
+}
+
+// Returns the hex encoded sha256 of the provided data
+func hashBytes(data ...[]byte) string {
+	h := sha256.New()
+
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Returns the hash of everything that affects every page: the config, the processor scripts, the menus and the
+// path and title of every page, since those end up in the menu and in links between pages
+func (p *Parser) configHash() string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "%d\n%s\n", manifestVersion, p.Config.Version)
+
+	if data, err := yaml.Marshal(p.Config); err == nil {
+		h.Write(data)
+	}
+
+	if data, err := yaml.Marshal(p.menus); err == nil {
+		h.Write(data)
+	}
+
+	for _, pl := range p.plugins {
+		fmt.Fprintf(h, "plugin %s\n", pl.Name())
+	}
+
+	for _, pc := range p.Config.Processors {
+		pc.writeHashes(h, p.Config.RootDir)
+	}
+
+	for _, f := range p.Files {
+		lang := ""
+
+		if f.lang != nil {
+			lang = f.lang.Code
+		}
+
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%t\n", f.Path, f.Title, f.SourceFile, lang, f.fallback)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
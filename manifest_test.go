@@ -0,0 +1,51 @@
+package zmdocs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigHashProcessorScript(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "zmdocs")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(rootDir)
+
+	script := filepath.Join(rootDir, "scripts", "process.js")
+
+	if err := os.MkdirAll(filepath.Dir(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := func(content string, command ...string) string {
+		t.Helper()
+
+		if err := ioutil.WriteFile(script, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		p := NewParser(&ParserConfig{
+			RootDir:    rootDir,
+			Processors: []*ProcessorConfig{{Command: command, Hooks: []string{ProcessorHookHTML}}},
+		})
+
+		return p.configHash()
+	}
+
+	if hash("a", "node", "scripts/process.js") == hash("b", "node", "scripts/process.js") {
+		t.Error("expected the hash to change when a script argument changes")
+	}
+
+	if hash("a", "./scripts/process.js") == hash("b", "./scripts/process.js") {
+		t.Error("expected the hash to change when the command changes")
+	}
+
+	if hash("a", "node", "--flag", "scripts") != hash("b", "node", "--flag", "scripts") {
+		t.Error("expected arguments that aren't files to be ignored")
+	}
+}
@@ -0,0 +1,945 @@
+package zmdocs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Supported OpenAPI page groupings
+const (
+	OpenAPIGroupByTag       = "tag"
+	OpenAPIGroupByOperation = "operation"
+)
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// OpenAPI 3 specification document. Only the parts used to generate reference pages are decoded.
+type OpenAPISpec struct {
+	OpenAPI    string                  `yaml:"openapi"`
+	Info       OpenAPIInfo             `yaml:"info"`
+	Servers    []*OpenAPIServer        `yaml:"servers"`
+	Paths      map[string]*OpenAPIPath `yaml:"paths"`
+	Components OpenAPIComponents       `yaml:"components"`
+	Tags       []*OpenAPITag           `yaml:"tags"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Version     string `yaml:"version"`
+}
+
+type OpenAPIServer struct {
+	URL         string `yaml:"url"`
+	Description string `yaml:"description"`
+}
+
+type OpenAPITag struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+type OpenAPIComponents struct {
+	Schemas       map[string]*OpenAPISchema      `yaml:"schemas"`
+	Parameters    map[string]*OpenAPIParameter   `yaml:"parameters"`
+	RequestBodies map[string]*OpenAPIRequestBody `yaml:"requestBodies"`
+	Responses     map[string]*OpenAPIResponse    `yaml:"responses"`
+}
+
+type OpenAPIPath struct {
+	Summary     string              `yaml:"summary"`
+	Description string              `yaml:"description"`
+	Parameters  []*OpenAPIParameter `yaml:"parameters"`
+	Get         *OpenAPIOperation   `yaml:"get"`
+	Put         *OpenAPIOperation   `yaml:"put"`
+	Post        *OpenAPIOperation   `yaml:"post"`
+	Delete      *OpenAPIOperation   `yaml:"delete"`
+	Options     *OpenAPIOperation   `yaml:"options"`
+	Head        *OpenAPIOperation   `yaml:"head"`
+	Patch       *OpenAPIOperation   `yaml:"patch"`
+	Trace       *OpenAPIOperation   `yaml:"trace"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `yaml:"operationId"`
+	Summary     string                      `yaml:"summary"`
+	Description string                      `yaml:"description"`
+	Tags        []string                    `yaml:"tags"`
+	Deprecated  bool                        `yaml:"deprecated"`
+	Parameters  []*OpenAPIParameter         `yaml:"parameters"`
+	RequestBody *OpenAPIRequestBody         `yaml:"requestBody"`
+	Responses   map[string]*OpenAPIResponse `yaml:"responses"`
+
+	Method string `yaml:"-"`
+	Path   string `yaml:"-"`
+}
+
+type OpenAPIParameter struct {
+	Ref         string         `yaml:"$ref"`
+	Name        string         `yaml:"name"`
+	In          string         `yaml:"in"`
+	Description string         `yaml:"description"`
+	Required    bool           `yaml:"required"`
+	Deprecated  bool           `yaml:"deprecated"`
+	Schema      *OpenAPISchema `yaml:"schema"`
+	Example     interface{}    `yaml:"example"`
+}
+
+type OpenAPIRequestBody struct {
+	Ref         string                       `yaml:"$ref"`
+	Description string                       `yaml:"description"`
+	Required    bool                         `yaml:"required"`
+	Content     map[string]*OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIResponse struct {
+	Ref         string                       `yaml:"$ref"`
+	Description string                       `yaml:"description"`
+	Content     map[string]*OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   *OpenAPISchema             `yaml:"schema"`
+	Example  interface{}                `yaml:"example"`
+	Examples map[string]*OpenAPIExample `yaml:"examples"`
+}
+
+type OpenAPIExample struct {
+	Summary string      `yaml:"summary"`
+	Value   interface{} `yaml:"value"`
+}
+
+type OpenAPISchema struct {
+	Ref         string                    `yaml:"$ref"`
+	Type        string                    `yaml:"type"`
+	Format      string                    `yaml:"format"`
+	Description string                    `yaml:"description"`
+	Properties  map[string]*OpenAPISchema `yaml:"properties"`
+	Required    []string                  `yaml:"required"`
+	Items       *OpenAPISchema            `yaml:"items"`
+	Enum        []interface{}             `yaml:"enum"`
+	Default     interface{}               `yaml:"default"`
+	Example     interface{}               `yaml:"example"`
+	Nullable    bool                      `yaml:"nullable"`
+	AllOf       []*OpenAPISchema          `yaml:"allOf"`
+	OneOf       []*OpenAPISchema          `yaml:"oneOf"`
+	AnyOf       []*OpenAPISchema          `yaml:"anyOf"`
+}
+
+// Loads an OpenAPI 3 specification from a YAML or JSON file
+func NewOpenAPISpecFromFile(path string) (*OpenAPISpec, error) {
+	var spec OpenAPISpec
+
+	if data, err := ioutil.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("unable to read spec file: %s", err.Error())
+	} else if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("unable to parse spec: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q, only 3.x specs are supported", spec.OpenAPI)
+	}
+
+	return &spec, nil
+}
+
+// Returns all operations of the spec, sorted by path and method
+func (s *OpenAPISpec) Operations() []*OpenAPIOperation {
+	paths := make([]string, 0, len(s.Paths))
+
+	for p := range s.Paths {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	ops := make([]*OpenAPIOperation, 0)
+
+	for _, p := range paths {
+		item := s.Paths[p]
+
+		if item == nil {
+			continue
+		}
+
+		for _, m := range openAPIMethods {
+			op := item.operation(m)
+
+			if op == nil {
+				continue
+			}
+
+			op.Method = m
+			op.Path = p
+			op.Parameters = append(append([]*OpenAPIParameter{}, item.Parameters...), op.Parameters...)
+
+			if op.Summary == "" {
+				op.Summary = item.Summary
+			}
+
+			ops = append(ops, op)
+		}
+	}
+
+	return ops
+}
+
+func (p *OpenAPIPath) operation(method string) *OpenAPIOperation {
+	switch method {
+	case "get":
+		return p.Get
+	case "put":
+		return p.Put
+	case "post":
+		return p.Post
+	case "delete":
+		return p.Delete
+	case "options":
+		return p.Options
+	case "head":
+		return p.Head
+	case "patch":
+		return p.Patch
+	case "trace":
+		return p.Trace
+	}
+
+	return nil
+}
+
+// Returns a stable anchor for the operation
+func (op *OpenAPIOperation) Anchor() string {
+	if op.OperationID != "" {
+		return "op-" + Slugify(op.OperationID)
+	}
+
+	return "op-" + Slugify(op.Method+" "+op.Path)
+}
+
+// Loads all OpenAPI specs and returns one file per tag or operation, plus a models page per spec
+func (p *Parser) loadOpenAPISpecs() error {
+	for i, src := range p.Config.OpenAPI {
+		specPath := src.SourceFile
+
+		if !filepath.IsAbs(specPath) {
+			specPath = filepath.Join(p.Config.RootDir, specPath)
+		}
+
+		log.WithFields(logrus.Fields{
+			"spec": specPath,
+		}).Debug("processing openapi spec")
+
+		spec, err := NewOpenAPISpecFromFile(specPath)
+
+		if err != nil {
+			return fmt.Errorf("unable to process openapi spec #%d: %s", i, err.Error())
+		}
+
+		files, err := GetFilesForOpenAPISpec(src, spec, specPath)
+
+		if err != nil {
+			return fmt.Errorf("unable to process openapi spec %s: \n\t%s", specPath, err.Error())
+		}
+
+		log.Debugf("generated %d openapi pages", len(files))
+
+		p.Files = append(p.Files, files...)
+	}
+
+	return nil
+}
+
+// Constructs the files for an OpenAPI spec.
+// Name, Path, Title and MenuGroup templates are executed against a PatternMatch with the `spec`, `kind`
+// ("tag", "operation" or "models"), `slug` and `title` groups set. Operation pages also have `method`,
+// `path` and `operationId` set.
+func GetFilesForOpenAPISpec(src *OpenAPISource, spec *OpenAPISpec, specPath string) ([]*File, error) {
+	r := openAPIRenderer{spec: spec}
+	specSlug := Slugify(spec.Info.Title)
+
+	if specSlug == "" {
+		specSlug = Slugify(strings.TrimSuffix(filepath.Base(specPath), filepath.Ext(specPath)))
+	}
+
+	newFile := func(groups map[string]string) (*File, error) {
+		groups["spec"] = specSlug
+		pm := &PatternMatch{Path: specPath, Groups: groups}
+
+		file := File{
+			Params: map[string]interface{}{
+				"openapi": groups,
+			},
+		}
+		file.AddToMenu = src.AddToMenu
+		file.EditOnGithub = src.EditOnGithub
+		file.Template = src.Template
+		file.SourceFile = specPath
+
+		nameTmpl := src.Name
+		pathTmpl := src.Path
+		titleTmpl := src.Title
+
+		if nameTmpl == "" {
+			nameTmpl = "{{ .Groups.spec }}-{{ .Groups.slug }}"
+		}
+
+		if pathTmpl == "" {
+			pathTmpl = "{{ .Groups.spec }}/{{ .Groups.slug }}"
+		}
+
+		if titleTmpl == "" {
+			titleTmpl = "{{ .Groups.title }}"
+		}
+
+		var err error
+
+		if file.Name, err = stringFromTemplate(nameTmpl, pm); err != nil {
+			return nil, fmt.Errorf("unable to parse name template: \n\t%s", err.Error())
+		} else if file.Path, err = stringFromTemplate(pathTmpl, pm); err != nil {
+			return nil, fmt.Errorf("unable to parse path template: \n\t%s", err.Error())
+		} else if file.Title, err = stringFromTemplate(titleTmpl, pm); err != nil {
+			return nil, fmt.Errorf("unable to parse title template: \n\t%s", err.Error())
+		} else if file.MenuGroup, err = stringFromTemplate(src.MenuGroup, pm); err != nil {
+			return nil, fmt.Errorf("unable to parse menu group template: \n\t%s", err.Error())
+		}
+
+		file.loaded = true
+
+		return &file, nil
+	}
+
+	files := make([]*File, 0)
+	var models *File
+
+	if len(spec.Components.Schemas) > 0 {
+		var err error
+
+		models, err = newFile(map[string]string{
+			"kind":  "models",
+			"slug":  "models",
+			"title": "Models",
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.modelsLink = models.Path
+		models.content = r.modelsMarkdown(models.Title)
+	}
+
+	ops := spec.Operations()
+
+	switch src.GroupBy {
+	case OpenAPIGroupByOperation:
+		for _, op := range ops {
+			title := op.Summary
+
+			if title == "" {
+				title = strings.ToUpper(op.Method) + " " + op.Path
+			}
+
+			f, err := newFile(map[string]string{
+				"kind":        "operation",
+				"slug":        strings.TrimPrefix(op.Anchor(), "op-"),
+				"title":       title,
+				"method":      op.Method,
+				"path":        op.Path,
+				"operationId": op.OperationID,
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			w := bytes.NewBuffer(make([]byte, 0))
+			r.writeOperation(w, op, "#")
+			f.content = w.Bytes()
+			files = append(files, f)
+		}
+
+	case OpenAPIGroupByTag, "":
+		for _, tag := range r.tags(ops) {
+			f, err := newFile(map[string]string{
+				"kind":  "tag",
+				"slug":  Slugify(tag.Name),
+				"title": tag.Name,
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			f.content = r.tagMarkdown(f.Title, tag, ops)
+			files = append(files, f)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown groupBy value %q, expected %q or %q", src.GroupBy, OpenAPIGroupByTag, OpenAPIGroupByOperation)
+	}
+
+	// models are listed last so they appear after the operations in menus
+	if models != nil {
+		files = append(files, models)
+	}
+
+	return files, nil
+}
+
+type openAPIRenderer struct {
+	spec       *OpenAPISpec
+	modelsLink string
+}
+
+// Returns the tags used by the operations, in the order they are declared in the spec.
+// Operations without tags are grouped under "default".
+func (r *openAPIRenderer) tags(ops []*OpenAPIOperation) []*OpenAPITag {
+	tags := make([]*OpenAPITag, 0)
+	seen := make(map[string]bool)
+
+	for _, t := range r.spec.Tags {
+		seen[t.Name] = true
+		tags = append(tags, t)
+	}
+
+	used := make(map[string]bool)
+
+	for _, op := range ops {
+		if len(op.Tags) == 0 {
+			op.Tags = []string{"default"}
+		}
+
+		for _, t := range op.Tags {
+			used[t] = true
+
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, &OpenAPITag{Name: t})
+			}
+		}
+	}
+
+	out := make([]*OpenAPITag, 0, len(tags))
+
+	for _, t := range tags {
+		if used[t.Name] {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func (r *openAPIRenderer) tagMarkdown(title string, tag *OpenAPITag, ops []*OpenAPIOperation) []byte {
+	w := bytes.NewBuffer(make([]byte, 0))
+
+	fmt.Fprintf(w, "# %s\n\n", title)
+
+	if tag.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", tag.Description)
+	}
+
+	r.writeServers(w)
+
+	for _, op := range ops {
+		for _, t := range op.Tags {
+			if t == tag.Name {
+				r.writeOperation(w, op, "##")
+				break
+			}
+		}
+	}
+
+	return w.Bytes()
+}
+
+func (r *openAPIRenderer) writeServers(w *bytes.Buffer) {
+	if len(r.spec.Servers) == 0 {
+		return
+	}
+
+	w.WriteString("| Server | Description |\n| --- | --- |\n")
+
+	for _, s := range r.spec.Servers {
+		fmt.Fprintf(w, "| `%s` | %s |\n", s.URL, tableCell(s.Description))
+	}
+
+	w.WriteString("\n")
+}
+
+func (r *openAPIRenderer) writeOperation(w *bytes.Buffer, op *OpenAPIOperation, level string) {
+	title := op.Summary
+
+	if title == "" {
+		title = fmt.Sprintf("`%s %s`", strings.ToUpper(op.Method), op.Path)
+	}
+
+	fmt.Fprintf(w, "%s %s {#%s}\n\n", level, title, op.Anchor())
+
+	if op.Summary != "" {
+		fmt.Fprintf(w, "`%s %s`\n\n", strings.ToUpper(op.Method), op.Path)
+	}
+
+	if op.Deprecated {
+		w.WriteString("**Deprecated**\n\n")
+	}
+
+	if op.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", op.Description)
+	}
+
+	sub := level + "#"
+
+	if len(op.Parameters) > 0 {
+		fmt.Fprintf(w, "%s Parameters {#%s-parameters}\n\n", sub, op.Anchor())
+		w.WriteString("| Name | In | Type | Required | Description |\n| --- | --- | --- | --- | --- |\n")
+
+		for _, param := range op.Parameters {
+			param = r.resolveParameter(param)
+
+			if param == nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s |\n", param.Name, param.In, r.schemaType(param.Schema), yesNo(param.Required), tableCell(param.Description))
+		}
+
+		w.WriteString("\n")
+	}
+
+	if body := r.resolveRequestBody(op.RequestBody); body != nil {
+		fmt.Fprintf(w, "%s Request body {#%s-request}\n\n", sub, op.Anchor())
+
+		if body.Description != "" {
+			fmt.Fprintf(w, "%s\n\n", body.Description)
+		}
+
+		r.writeContent(w, body.Content)
+	}
+
+	if len(op.Responses) > 0 {
+		fmt.Fprintf(w, "%s Responses {#%s-responses}\n\n", sub, op.Anchor())
+
+		codes := make([]string, 0, len(op.Responses))
+
+		for code := range op.Responses {
+			codes = append(codes, code)
+		}
+
+		sort.Strings(codes)
+
+		w.WriteString("| Code | Description | Schema |\n| --- | --- | --- |\n")
+
+		for _, code := range codes {
+			res := r.resolveResponse(op.Responses[code])
+
+			if res == nil {
+				continue
+			}
+
+			schemas := make([]string, 0)
+
+			for _, ct := range sortedKeys(res.Content) {
+				if mt := res.Content[ct]; mt != nil && mt.Schema != nil {
+					schemas = append(schemas, r.schemaType(mt.Schema))
+				}
+			}
+
+			fmt.Fprintf(w, "| %s | %s | %s |\n", code, tableCell(res.Description), strings.Join(schemas, ", "))
+		}
+
+		w.WriteString("\n")
+
+		for _, code := range codes {
+			res := r.resolveResponse(op.Responses[code])
+
+			if res == nil || len(res.Content) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(w, "**%s response**\n\n", code)
+			r.writeContent(w, res.Content)
+		}
+	}
+}
+
+// Writes the schema and an example for each content type
+func (r *openAPIRenderer) writeContent(w *bytes.Buffer, content map[string]*OpenAPIMediaType) {
+	for _, ct := range sortedKeys(content) {
+		mt := content[ct]
+
+		if mt == nil {
+			continue
+		}
+
+		fmt.Fprintf(w, "Content type: `%s`", ct)
+
+		if mt.Schema != nil {
+			fmt.Fprintf(w, ", schema: %s", r.schemaType(mt.Schema))
+		}
+
+		w.WriteString("\n\n")
+
+		if len(mt.Examples) > 0 {
+			for _, name := range sortedKeys(mt.Examples) {
+				ex := mt.Examples[name]
+
+				if ex == nil {
+					continue
+				}
+
+				label := ex.Summary
+
+				if label == "" {
+					label = name
+				}
+
+				fmt.Fprintf(w, "Example (%s):\n\n", label)
+				writeJSONExample(w, ex.Value)
+			}
+		} else if mt.Example != nil {
+			writeJSONExample(w, mt.Example)
+		} else if mt.Schema != nil {
+			writeJSONExample(w, r.sample(mt.Schema, 0))
+		}
+	}
+}
+
+func (r *openAPIRenderer) modelsMarkdown(title string) []byte {
+	w := bytes.NewBuffer(make([]byte, 0))
+
+	fmt.Fprintf(w, "# %s\n\n", title)
+
+	for _, name := range sortedKeys(r.spec.Components.Schemas) {
+		s := r.spec.Components.Schemas[name]
+
+		if s == nil {
+			continue
+		}
+
+		fmt.Fprintf(w, "## %s {#%s}\n\n", name, name)
+
+		if s.Description != "" {
+			fmt.Fprintf(w, "%s\n\n", s.Description)
+		}
+
+		props := r.properties(s)
+
+		if len(props) > 0 {
+			required := make(map[string]bool)
+
+			for _, req := range r.required(s) {
+				required[req] = true
+			}
+
+			w.WriteString("| Property | Type | Required | Description |\n| --- | --- | --- | --- |\n")
+
+			for _, pn := range sortedKeys(props) {
+				prop := props[pn]
+				desc := ""
+
+				if prop != nil {
+					desc = prop.Description
+
+					if len(prop.Enum) > 0 {
+						desc = strings.TrimSpace(fmt.Sprintf("%s One of: %s", desc, enumString(prop.Enum)))
+					}
+				}
+
+				fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", pn, r.schemaType(prop), yesNo(required[pn]), tableCell(desc))
+			}
+
+			w.WriteString("\n")
+		} else {
+			fmt.Fprintf(w, "Type: %s\n\n", r.schemaType(s))
+
+			if len(s.Enum) > 0 {
+				fmt.Fprintf(w, "One of: %s\n\n", enumString(s.Enum))
+			}
+		}
+
+		w.WriteString("Example:\n\n")
+		writeJSONExample(w, r.sample(s, 0))
+	}
+
+	return w.Bytes()
+}
+
+// Returns the properties of a schema, including the ones of allOf members
+func (r *openAPIRenderer) properties(s *OpenAPISchema) map[string]*OpenAPISchema {
+	props := make(map[string]*OpenAPISchema)
+	s = r.resolveSchema(s)
+
+	if s == nil {
+		return props
+	}
+
+	for _, member := range s.AllOf {
+		for k, v := range r.properties(member) {
+			props[k] = v
+		}
+	}
+
+	for k, v := range s.Properties {
+		props[k] = v
+	}
+
+	return props
+}
+
+func (r *openAPIRenderer) required(s *OpenAPISchema) []string {
+	s = r.resolveSchema(s)
+
+	if s == nil {
+		return nil
+	}
+
+	req := append([]string{}, s.Required...)
+
+	for _, member := range s.AllOf {
+		req = append(req, r.required(member)...)
+	}
+
+	return req
+}
+
+// Returns a markdown description of the schema type, referenced models are linked to the models page
+func (r *openAPIRenderer) schemaType(s *OpenAPISchema) string {
+	if s == nil {
+		return ""
+	}
+
+	if s.Ref != "" {
+		name := refName(s.Ref)
+		return fmt.Sprintf("[%s](%s#%s)", name, r.modelsLink, name)
+	}
+
+	var t string
+
+	switch {
+	case s.Type == "array":
+		t = "array of " + r.schemaType(s.Items)
+	case len(s.OneOf) > 0:
+		t = "one of " + r.schemaTypes(s.OneOf)
+	case len(s.AnyOf) > 0:
+		t = "any of " + r.schemaTypes(s.AnyOf)
+	case len(s.AllOf) > 0:
+		t = "all of " + r.schemaTypes(s.AllOf)
+	case s.Type == "":
+		t = "object"
+	default:
+		t = s.Type
+	}
+
+	if s.Format != "" {
+		t = fmt.Sprintf("%s (%s)", t, s.Format)
+	}
+
+	if s.Nullable {
+		t += ", nullable"
+	}
+
+	return t
+}
+
+func (r *openAPIRenderer) schemaTypes(schemas []*OpenAPISchema) string {
+	types := make([]string, len(schemas))
+
+	for i, s := range schemas {
+		types[i] = r.schemaType(s)
+	}
+
+	return strings.Join(types, ", ")
+}
+
+// Builds an example value from a schema
+func (r *openAPIRenderer) sample(s *OpenAPISchema, depth int) interface{} {
+	s = r.resolveSchema(s)
+
+	if s == nil || depth > 8 {
+		return nil
+	}
+
+	if s.Example != nil {
+		return s.Example
+	}
+
+	if s.Default != nil {
+		return s.Default
+	}
+
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+
+	switch {
+	case len(s.OneOf) > 0:
+		return r.sample(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return r.sample(s.AnyOf[0], depth+1)
+	}
+
+	switch s.Type {
+	case "array":
+		return []interface{}{r.sample(s.Items, depth+1)}
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "date":
+			return "2006-01-02"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "email":
+			return "user@example.com"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		return "string"
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return true
+	}
+
+	obj := make(map[string]interface{})
+
+	for name, prop := range r.properties(s) {
+		obj[name] = r.sample(prop, depth+1)
+	}
+
+	return obj
+}
+
+func (r *openAPIRenderer) resolveSchema(s *OpenAPISchema) *OpenAPISchema {
+	for i := 0; s != nil && s.Ref != "" && i < 16; i++ {
+		s = r.spec.Components.Schemas[refName(s.Ref)]
+	}
+
+	return s
+}
+
+func (r *openAPIRenderer) resolveParameter(p *OpenAPIParameter) *OpenAPIParameter {
+	for i := 0; p != nil && p.Ref != "" && i < 16; i++ {
+		p = r.spec.Components.Parameters[refName(p.Ref)]
+	}
+
+	return p
+}
+
+func (r *openAPIRenderer) resolveRequestBody(b *OpenAPIRequestBody) *OpenAPIRequestBody {
+	for i := 0; b != nil && b.Ref != "" && i < 16; i++ {
+		b = r.spec.Components.RequestBodies[refName(b.Ref)]
+	}
+
+	return b
+}
+
+func (r *openAPIRenderer) resolveResponse(res *OpenAPIResponse) *OpenAPIResponse {
+	for i := 0; res != nil && res.Ref != "" && i < 16; i++ {
+		res = r.spec.Components.Responses[refName(res.Ref)]
+	}
+
+	return res
+}
+
+// Returns the component name of a local reference, e.g. "#/components/schemas/Pet" -> "Pet"
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func writeJSONExample(w *bytes.Buffer, v interface{}) {
+	data, err := json.MarshalIndent(jsonCompatible(v), "", "  ")
+
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(w, "```json\n%s\n```\n\n", data)
+}
+
+// Converts YAML decoded maps (map[interface{}]interface{}) to values that can be JSON encoded
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+
+		for k, v := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(v)
+		}
+
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+
+		for k, v := range t {
+			m[k] = jsonCompatible(v)
+		}
+
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+
+		for i, v := range t {
+			s[i] = jsonCompatible(v)
+		}
+
+		return s
+	default:
+		return v
+	}
+}
+
+func enumString(values []interface{}) string {
+	s := make([]string, len(values))
+
+	for i, v := range values {
+		s[i] = fmt.Sprintf("`%v`", v)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func tableCell(s string) string {
+	return strings.Replace(strings.Replace(strings.TrimSpace(s), "|", `\|`, -1), "\n", " ", -1)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+
+	switch t := m.(type) {
+	case map[string]*OpenAPIMediaType:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]*OpenAPIExample:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]*OpenAPISchema:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
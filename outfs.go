@@ -0,0 +1,122 @@
+package zmdocs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File system the rendered site is written to. Paths are absolute paths under the output directory.
+type OutputFS interface {
+	// Writes the file, creating its parent directories. A zero modTime uses the current time.
+	WriteFile(name string, data []byte, modTime time.Time) error
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (os.FileInfo, error)
+}
+
+// Writes the output to the disk
+type DiskFS struct{}
+
+func (DiskFS) WriteFile(name string, data []byte, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		return err
+	}
+
+	if !modTime.IsZero() {
+		return os.Chtimes(name, modTime, modTime)
+	}
+
+	return nil
+}
+
+func (DiskFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (DiskFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Keeps the output in memory, used by the dev server to build without touching the output directory.
+// It's safe for concurrent use.
+type MemFS struct {
+	mtx   sync.RWMutex
+	files map[string]*memFile
+}
+
+type memFile struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+// Returns an empty in-memory file system
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: make(map[string]*memFile),
+	}
+}
+
+func (fs *MemFS) WriteFile(name string, data []byte, modTime time.Time) error {
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+
+	f := memFile{
+		name:    filepath.Base(name),
+		data:    append([]byte(nil), data...),
+		modTime: modTime,
+	}
+
+	fs.mtx.Lock()
+	fs.files[filepath.Clean(name)] = &f
+	fs.mtx.Unlock()
+
+	return nil
+}
+
+func (fs *MemFS) ReadFile(name string) ([]byte, error) {
+	fs.mtx.RLock()
+	f, ok := fs.files[filepath.Clean(name)]
+	fs.mtx.RUnlock()
+
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+
+	return f.data, nil
+}
+
+// Returns the info of a file. Directories are not tracked, so Stat fails for them.
+func (fs *MemFS) Stat(name string) (os.FileInfo, error) {
+	fs.mtx.RLock()
+	f, ok := fs.files[filepath.Clean(name)]
+	fs.mtx.RUnlock()
+
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return f, nil
+}
+
+// Returns the number of files
+func (fs *MemFS) Len() int {
+	fs.mtx.RLock()
+	defer fs.mtx.RUnlock()
+
+	return len(fs.files)
+}
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Size() int64        { return int64(len(f.data)) }
+func (f *memFile) Mode() os.FileMode  { return 0644 }
+func (f *memFile) ModTime() time.Time { return f.modTime }
+func (f *memFile) IsDir() bool        { return false }
+func (f *memFile) Sys() interface{}   { return nil }
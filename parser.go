@@ -1,16 +1,21 @@
 package zmdocs
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // A parser loads files from the provided config
 type Parser struct {
 	Config *ParserConfig
 	Files  []*File
+
+	menus   map[string][]*MenuItem // Localized menus by language code
+	plugins []Plugin               // Registered plugins, see Use
 }
 
 // Returns a new Parser instance from the provided config
@@ -28,11 +33,19 @@ func NewParser(config *ParserConfig) *Parser {
 		log.SetLevel(logrus.InfoLevel)
 	}
 
+	if config.OutDir == "" {
+		config.OutDir = filepath.Join(config.RootDir, DefaultOutDir)
+	}
+
 	p := Parser{
 		Config: config,
 		Files:  make([]*File, 0),
 	}
 
+	for _, pc := range config.Processors {
+		p.Use(newProcessorPlugin(pc, config.RootDir))
+	}
+
 	log.WithFields(logrus.Fields{
 		"rootDir":   config.RootDir,
 		"outDir":    config.OutDir,
@@ -56,12 +69,63 @@ func NewParserFromConfigFile(configPath string) (*Parser, error) {
 // Returns a Renderer instance from the parsed files
 // This must be ran after a successful LoadSourceFiles so there are files to render
 func (p *Parser) Renderer() (*Renderer, error) {
-	if p.Config.BaseURL != "" {
+	if p.Config.CleanOutDir {
+		if err := p.checkCleanOutDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.Config.Languages) > 0 {
+		p.menus = make(map[string][]*MenuItem)
+
+		for _, lang := range p.Config.Languages {
+			p.menus[lang.Code] = localizeMenu(p.Config.MenuItems, lang)
+		}
+	} else if p.Config.BaseURL != "" {
 		for _, it := range p.Config.MenuItems {
 			p.handleHomePage(it)
 		}
 	}
 
+	if err := forEachParallel(p.Config.Jobs, len(p.Files), func(i int) error {
+		if err := p.Files[i].Parse(p); err != nil {
+			return fmt.Errorf("%s: %s", p.Files[i].SourceFile, err.Error())
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to parse files: %s", err.Error())
+	}
+
+	linkErrs := make([]error, 0)
+
+	for _, files := range p.filesByLanguage() {
+		idx := newLinkIndex(files)
+		p.addLanguageAliases(idx, files)
+
+		for _, f := range files {
+			linkErrs = append(linkErrs, p.resolveLinks(f, idx)...)
+		}
+	}
+
+	for _, err := range linkErrs {
+		log.Warn(err.Error())
+	}
+
+	if p.Config.StrictLinks && len(linkErrs) > 0 {
+		return nil, fmt.Errorf("found %d broken links", len(linkErrs))
+	}
+
+	assets, err := p.loadAssets()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range p.Files {
+		assets = append(assets, f.assets...)
+	}
+
 	rndCtxs := make([]*RenderContext, 0)
 
 	for _, f := range p.Files {
@@ -72,43 +136,174 @@ func (p *Parser) Renderer() (*Renderer, error) {
 		}
 
 		if f.AddToMenu {
-			f.AppendToMenu(p.Config.MenuItems)
+			if f.lang != nil {
+				f.AppendToMenu(p.menus[f.lang.Code])
+			} else {
+				f.AppendToMenu(p.Config.MenuItems)
+			}
 		}
 	}
 
+	if len(p.Config.Languages) > 0 {
+		setTranslations(p.Files, rndCtxs)
+	}
+
+	configHash := p.configHash()
+
+	for _, ctx := range rndCtxs {
+		ctx.inputs.Config = configHash
+	}
+
 	rnd := &Renderer{
-		MenuItems: p.Config.MenuItems,
-		Contexts:  rndCtxs,
-		Templates: p.Config.Templates,
+		MenuItems:   p.Config.MenuItems,
+		Contexts:    rndCtxs,
+		Templates:   p.Config.Templates,
+		PartialsDir: p.Config.PartialsDir,
+		OutDir:      p.Config.OutDir,
+		CleanOutDir: p.Config.CleanOutDir,
+		Highlight:   p.Config.Highlight,
+		Assets:      uniqueAssets(assets),
+		Search:      p.Config.Search,
+		Sitemap:     p.Config.Sitemap,
+		Robots:      p.Config.Robots,
+		BaseURL:     p.Config.BaseURL,
+		RootDir:     p.Config.RootDir,
+		Languages:   p.Config.Languages,
+		Jobs:        p.Config.Jobs,
+		Plugins:     p.plugins,
 	}
 
 	return rnd, nil
 }
 
+// Returns an error if cleaning the output directory could delete inputs of the build: the root directory,
+// page sources, templates, partials or assets inside of the output directory
+func (p *Parser) checkCleanOutDir() error {
+	c := p.Config
+
+	if p.InOutDir(c.RootDir) {
+		return errors.New("cleanOutDir cannot be used when the root directory is inside the output directory")
+	}
+
+	inputs := make([]string, 0)
+
+	for _, f := range p.Files {
+		inputs = append(inputs, f.SourceFile)
+	}
+
+	for _, t := range c.Templates {
+		inputs = append(inputs, t.SourceFile)
+	}
+
+	if c.PartialsDir != "" {
+		inputs = append(inputs, c.PartialsDir)
+	}
+
+	for _, a := range c.Assets {
+		if !filepath.IsAbs(a) {
+			a = filepath.Join(c.RootDir, a)
+		}
+
+		if strings.ContainsAny(a, `*?[`) {
+			a = globBaseDir(a)
+		}
+
+		inputs = append(inputs, a)
+	}
+
+	for _, in := range inputs {
+		if in != "" && p.InOutDir(in) {
+			return fmt.Errorf("cleanOutDir cannot be used when %s is inside the output directory", in)
+		}
+	}
+
+	return nil
+}
+
 // Load all source files
 func (p *Parser) LoadSourceFiles() error {
+	for _, pl := range p.allPlugins() {
+		if err := pl.ConfigLoaded(p.Config); err != nil {
+			return fmt.Errorf("%s: %s", pl.Name(), err.Error())
+		}
+	}
+
 	log.Info("loading source files")
 
+	if len(p.Config.Languages) > 0 {
+		log.Debugf("Loading files for %d languages", len(p.Config.Languages))
+		if err := p.loadLanguageFiles(); err != nil {
+			return err
+		}
+	} else if err := p.loadFiles(); err != nil {
+		return err
+	}
+
+	for _, pl := range p.allPlugins() {
+		if err := pl.FilesLoaded(p); err != nil {
+			return fmt.Errorf("%s: %s", pl.Name(), err.Error())
+		}
+	}
+
+	def := p.Config.DefaultLanguage()
+
+	for _, f := range p.Files {
+		// files added by plugins are loaded here, and belong to the default language
+		if err := f.Load(); err != nil {
+			return fmt.Errorf("unable to load file: %s", err.Error())
+		}
+
+		if def != nil && f.lang == nil {
+			f.lang = def
+			f.translationKey = "\x00" + normalizePagePath(f.Path)
+			f.Path = languagePath(def, f.Path)
+		}
+	}
+
+	log.Infof("loaded %d files", len(p.Files))
+
+	return nil
+}
+
+// Loads the files of a site without languages
+func (p *Parser) loadFiles() error {
 	log.Debug("Loading static files")
-	if err := p.loadStaticFiles(); err != nil {
+	if err := p.loadStaticFiles(p.Config.RootDir); err != nil {
 		return err
 	}
 	log.Debug("Done loading static files")
 
 	log.Debug("Loading glob files")
-	if err := p.loadGlobFiles(); err != nil {
+	if err := p.loadGlobFiles(p.Config.RootDir); err != nil {
 		return err
 	}
 	log.Debug("Done loading glob files")
 
-	log.Infof("loaded %d files", len(p.Files))
+	log.Debug("Loading go packages")
+	if err := p.loadGoPackages(); err != nil {
+		return err
+	}
+	log.Debug("Done loading go packages")
+
+	log.Debug("Loading openapi specs")
+	if err := p.loadOpenAPISpecs(); err != nil {
+		return err
+	}
+	log.Debug("Done loading openapi specs")
+
+	for _, f := range p.Files {
+		if err := f.Load(); err != nil {
+			return fmt.Errorf("unable to load file: %s", err.Error())
+		}
+	}
 
 	return nil
 }
 
-func (p *Parser) loadStaticFiles() error {
+// Loads the static pages with sources relative to rootDir
+func (p *Parser) loadStaticFiles(rootDir string) error {
 	for _, pg := range p.Config.Pages {
-		g := filepath.Join(p.Config.RootDir, pg.SourceFile)
+		g := filepath.Join(rootDir, pg.SourceFile)
 
 		file := File{
 			BasePage: *&pg.BasePage,
@@ -123,16 +318,25 @@ func (p *Parser) loadStaticFiles() error {
 	return nil
 }
 
-func (p *Parser) loadGlobFiles() error {
+// Loads the pattern pages with globs relative to rootDir, skipping files matching any of the extra exclude globs
+func (p *Parser) loadGlobFiles(rootDir string, extraExclude ...string) error {
 	for i, ap := range p.Config.AutoPages {
-		g := filepath.Join(p.Config.RootDir, ap.SourceGlob)
+		g := filepath.Join(rootDir, ap.SourceGlob)
 
 		log.WithFields(logrus.Fields{
 			"pattern": ap.Pattern,
 			"absPath": g,
 		}).Debug("processing page pattern")
 
-		if patternMatches, err := GetPatternMatches(g, ap.Pattern); err != nil {
+		exclude := make([]string, len(ap.Exclude), len(ap.Exclude)+len(extraExclude))
+
+		for j, ex := range ap.Exclude {
+			exclude[j] = filepath.Join(rootDir, ex)
+		}
+
+		exclude = append(exclude, extraExclude...)
+
+		if patternMatches, err := GetPatternMatches(g, ap.Pattern, exclude...); err != nil {
 			return fmt.Errorf("unable to process page pattern #%d: %s", i, err.Error())
 		} else {
 			log.WithFields(logrus.Fields{
@@ -167,3 +371,26 @@ func (p *Parser) handleHomePage(it *MenuItem) {
 		it.Link = p.Config.BaseURL
 	}
 }
+
+// Groups the files by language so links are resolved against pages of the same language
+func (p *Parser) filesByLanguage() [][]*File {
+	if len(p.Config.Languages) == 0 {
+		return [][]*File{p.Files}
+	}
+
+	groups := make([][]*File, 0, len(p.Config.Languages))
+
+	for _, lang := range p.Config.Languages {
+		files := make([]*File, 0)
+
+		for _, f := range p.Files {
+			if f.lang == lang {
+				files = append(files, f)
+			}
+		}
+
+		groups = append(groups, files)
+	}
+
+	return groups
+}
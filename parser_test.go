@@ -0,0 +1,96 @@
+package zmdocs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckCleanOutDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "zmdocs")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	rootDir := filepath.Join(tmp, "project")
+
+	tests := []struct {
+		name      string
+		outDir    string
+		templates []*Template
+		assets    []string
+		wantErr   string
+	}{
+		{name: "root directory", outDir: rootDir, wantErr: "root directory"},
+		{name: "parent directory", outDir: tmp, wantErr: "root directory"},
+		{name: "template", outDir: filepath.Join(rootDir, "out"), templates: []*Template{{Name: "page", SourceFile: filepath.Join(rootDir, "out", "page.html")}}, wantErr: "page.html"},
+		{name: "asset directory", outDir: filepath.Join(rootDir, "static"), assets: []string{"static"}, wantErr: "static"},
+		{name: "asset glob", outDir: filepath.Join(rootDir, "out"), assets: []string{"out/img/*.png"}, wantErr: "img"},
+		{name: "sibling directory", outDir: filepath.Join(rootDir, "out"), assets: []string{"outside/*.png"}},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(&ParserConfig{
+			RootDir:     rootDir,
+			OutDir:      tt.outDir,
+			CleanOutDir: true,
+			Templates:   tt.templates,
+			Assets:      tt.assets,
+		})
+
+		err := p.checkCleanOutDir()
+
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", tt.name, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: expected cleanOutDir to be rejected", tt.name)
+		} else if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error to mention %q, got %q", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestRendererRejectsParentOutDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "zmdocs")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	rootDir := filepath.Join(tmp, "project")
+	sibling := filepath.Join(tmp, "unrelated.txt")
+
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(sibling, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser(&ParserConfig{
+		RootDir:     rootDir,
+		OutDir:      filepath.Join(rootDir, ".."),
+		CleanOutDir: true,
+	})
+
+	if _, err := p.Renderer(); err == nil {
+		t.Fatal("expected cleanOutDir with a parent output directory to be rejected")
+	}
+
+	if _, err := os.Stat(sibling); err != nil {
+		t.Errorf("expected %s to be kept: %s", sibling, err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"path/filepath"
 	"regexp"
 	"text/template"
 )
@@ -13,10 +12,12 @@ import (
 type PatternMatch struct {
 	Path        string
 	PathMatches [][]string
+	Groups      map[string]string // Named capture groups of the first match, e.g. `(?P<name>...)` is available as `.Groups.name`
 }
 
-// Finds files that match a glob (source) and finds pattern matches on each file
-func GetPatternMatches(source, pattern string) ([]*PatternMatch, error) {
+// Finds files that match a glob (source) and finds pattern matches on each file.
+// Files matching any of the exclude globs are skipped. Matches are sorted by path.
+func GetPatternMatches(source, pattern string, exclude ...string) ([]*PatternMatch, error) {
 	if source == "" {
 		return nil, errors.New("source glob is required")
 	}
@@ -31,12 +32,16 @@ func GetPatternMatches(source, pattern string) ([]*PatternMatch, error) {
 		return nil, fmt.Errorf("unable to compile regex pattern: %s", pattern)
 	}
 
-	matches, err := filepath.Glob(source)
+	matches, err := Glob(source)
 
 	if err != nil {
 		return nil, fmt.Errorf("unable to find files matching glob (%s): %s", source, err.Error())
 	}
 
+	if len(exclude) > 0 {
+		matches = excludeMatches(matches, exclude)
+	}
+
 	mLen := len(matches)
 
 	if mLen == 0 {
@@ -51,6 +56,15 @@ func GetPatternMatches(source, pattern string) ([]*PatternMatch, error) {
 		pm := PatternMatch{
 			Path:        path,
 			PathMatches: pathMatches,
+			Groups:      make(map[string]string),
+		}
+
+		if len(pathMatches) > 0 {
+			for gi, name := range rgx.SubexpNames() {
+				if name != "" {
+					pm.Groups[name] = pathMatches[0][gi]
+				}
+			}
 		}
 
 		pms[i] = &pm
@@ -59,6 +73,30 @@ func GetPatternMatches(source, pattern string) ([]*PatternMatch, error) {
 	return pms, nil
 }
 
+// Returns the paths that don't match any of the exclude globs
+func excludeMatches(matches, exclude []string) []string {
+	out := make([]string, 0, len(matches))
+
+	for _, path := range matches {
+		excluded := false
+
+		for _, ex := range exclude {
+			if MatchGlob(ex, path) {
+				excluded = true
+				break
+			}
+		}
+
+		if excluded {
+			log.Debugf("excluding %s", path)
+		} else {
+			out = append(out, path)
+		}
+	}
+
+	return out
+}
+
 // Constructs a File instance from a pattern match
 func GetFileForPatternMatch(ap *PagePattern, pm *PatternMatch) (*File, error) {
 	if ap == nil {
@@ -72,7 +110,6 @@ func GetFileForPatternMatch(ap *PagePattern, pm *PatternMatch) (*File, error) {
 	file := File{}
 	file.EditOnGithub = ap.EditOnGithub
 	file.AddToMenu = ap.AddToMenu
-	file.MenuGroup = ap.MenuGroup
 	file.Template = ap.Template
 	file.SourceFile = pm.Path
 
@@ -82,14 +119,22 @@ func GetFileForPatternMatch(ap *PagePattern, pm *PatternMatch) (*File, error) {
 		return nil, fmt.Errorf("unable to parse name template: \n\t%s", err.Error())
 	} else if file.Path, err = stringFromTemplate(ap.Path, pm); err != nil {
 		return nil, fmt.Errorf("unable to parse path template: \n\t%s", err.Error())
+	} else if file.Title, err = stringFromTemplate(ap.Title, pm); err != nil {
+		return nil, fmt.Errorf("unable to parse title template: \n\t%s", err.Error())
+	} else if file.MenuGroup, err = stringFromTemplate(ap.MenuGroup, pm); err != nil {
+		return nil, fmt.Errorf("unable to parse menu group template: \n\t%s", err.Error())
 	}
 
 	return &file, nil
 }
 
-// Utility function to generate a string from a provided Go template
+// Utility function to generate a string from a provided Go template, see PatternFuncs for available functions
 func stringFromTemplate(t string, pm *PatternMatch) (string, error) {
-	if tmpl, err := template.New("").Parse(t); err != nil {
+	if t == "" {
+		return "", nil
+	}
+
+	if tmpl, err := template.New("").Funcs(PatternFuncs).Parse(t); err != nil {
 		return "", fmt.Errorf("unable to parse template: \n\t%s", err.Error())
 	} else {
 		buff := bytes.NewBuffer(make([]byte, 0))
@@ -0,0 +1,135 @@
+package zmdocs
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// A plugin adds behavior to the build pipeline. Hooks are called in the order plugins are registered, after the
+// built-in plugins. TransformMarkdown and TransformHTML are called for several pages in parallel, so they must be
+// safe for concurrent use. Embed BasePlugin to only implement some of the hooks.
+type Plugin interface {
+	// Name of the plugin, used in errors and to detect when the set of plugins changes between builds
+	Name() string
+
+	// Called before the source files are loaded, the config can be modified
+	ConfigLoaded(c *ParserConfig) error
+
+	// Called once the source files are loaded, files can be added to or removed from p.Files
+	FilesLoaded(p *Parser) error
+
+	// Called with the markdown of a page before it's parsed. Returns the markdown to parse.
+	TransformMarkdown(f *File, src []byte) ([]byte, error)
+
+	// Called with the HTML of a page before it's written. Returns the HTML to write.
+	TransformHTML(ctx *RenderContext, html []byte) ([]byte, error)
+
+	// Called once all pages are written
+	AfterRender(site *Site) error
+}
+
+// Implements all the hooks of Plugin as no-ops
+type BasePlugin struct{}
+
+func (BasePlugin) ConfigLoaded(c *ParserConfig) error { return nil }
+func (BasePlugin) FilesLoaded(p *Parser) error        { return nil }
+
+func (BasePlugin) TransformMarkdown(f *File, src []byte) ([]byte, error) {
+	return src, nil
+}
+
+func (BasePlugin) TransformHTML(ctx *RenderContext, html []byte) ([]byte, error) {
+	return html, nil
+}
+
+func (BasePlugin) AfterRender(site *Site) error { return nil }
+
+// The rendered site, passed to AfterRender
+type Site struct {
+	Pages   []*RenderContext
+	OutDir  string
+	BaseURL string
+	FS      OutputFS
+
+	r *Renderer
+}
+
+// Writes a file to the output directory, name is relative to it. Files written with WriteFile are kept
+// when the output directory is cleaned.
+func (s *Site) WriteFile(name string, data []byte) error {
+	dest := filepath.Join(s.OutDir, filepath.FromSlash(name))
+
+	if err := s.FS.WriteFile(dest, data, time.Time{}); err != nil {
+		return err
+	}
+
+	s.r.track(dest)
+
+	return nil
+}
+
+// Returns the plugins every build runs, in order
+func builtinPlugins() []Plugin {
+	return []Plugin{
+		fencePlugin{},
+		searchPlugin{},
+		sitemapPlugin{},
+		languageRedirectPlugin{},
+		highlightPlugin{},
+	}
+}
+
+// Registers plugins, their hooks are called after the ones of plugins registered before them
+func (p *Parser) Use(plugins ...Plugin) {
+	p.plugins = append(p.plugins, plugins...)
+}
+
+// Returns the built-in plugins followed by the registered ones
+func (p *Parser) allPlugins() []Plugin {
+	return append(builtinPlugins(), p.plugins...)
+}
+
+// Passes the markdown of f through the TransformMarkdown hook of every plugin
+func (p *Parser) transformMarkdown(f *File, src []byte) ([]byte, error) {
+	var err error
+
+	for _, pl := range p.allPlugins() {
+		if src, err = pl.TransformMarkdown(f, src); err != nil {
+			return nil, fmt.Errorf("%s: %s", pl.Name(), err.Error())
+		}
+	}
+
+	return src, nil
+}
+
+// Passes the HTML of the page through the TransformHTML hook of every plugin
+func (c *RenderContext) transformHTML(html []byte) ([]byte, error) {
+	var err error
+
+	for _, pl := range c.plugins {
+		if html, err = pl.TransformHTML(c, html); err != nil {
+			return nil, fmt.Errorf("%s: %s", pl.Name(), err.Error())
+		}
+	}
+
+	return html, nil
+}
+
+// Returns the built-in plugins followed by the renderer plugins
+func (r *Renderer) allPlugins() []Plugin {
+	return append(builtinPlugins(), r.Plugins...)
+}
+
+// Rewrites fence attributes so blackfriday can parse them
+type fencePlugin struct {
+	BasePlugin
+}
+
+func (fencePlugin) Name() string {
+	return "fence-attributes"
+}
+
+func (fencePlugin) TransformMarkdown(f *File, src []byte) ([]byte, error) {
+	return normalizeFenceAttributes(src), nil
+}
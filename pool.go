@@ -0,0 +1,80 @@
+package zmdocs
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Errors from several pages of the same build
+type BuildErrors []error
+
+func (e BuildErrors) Error() string {
+	msgs := make([]string, len(e))
+
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+
+	return strings.Join(append([]string{"multiple errors:"}, msgs...), "\n\t")
+}
+
+// Returns the number of workers to use, defaults to the number of CPUs
+func workerCount(jobs int) int {
+	if jobs > 0 {
+		return jobs
+	}
+
+	return runtime.NumCPU()
+}
+
+// Calls fn for every index in [0, n) on at most `jobs` goroutines.
+// Returns the errors of all calls in index order, or nil if all of them succeeded.
+func forEachParallel(jobs, n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+
+	workers := workerCount(jobs)
+
+	if workers > n {
+		workers = n
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range work {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+
+	close(work)
+	wg.Wait()
+
+	out := make(BuildErrors, 0)
+
+	for _, err := range errs {
+		if err != nil {
+			out = append(out, err)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return out
+}
@@ -0,0 +1,415 @@
+package zmdocs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Hooks a processor can be called for
+const (
+	ProcessorHookPages = "pages" // Once the source files are loaded, with every page. Pages can be modified and added.
+	ProcessorHookHTML  = "html"  // For every rendered page, with its HTML. The HTML can be modified.
+)
+
+// Maximum duration of a processor call when none is configured
+const DefaultProcessorTimeout = 30 * time.Second
+
+// Maximum length of the processor output included in errors
+const processorStderrLimit = 2048
+
+// External command called during the build. The command is started for every call, it reads a ProcessorRequest
+// as JSON from stdin and writes a ProcessorResponse as JSON to stdout. Anything written to stderr is logged, or
+// included in the error if the command fails.
+type ProcessorConfig struct {
+	Name    string   `yaml:"name"`    // Name used in logs and errors, defaults to the command name
+	Command []string `yaml:"command"` // Command and its arguments, run from the root directory
+	Hooks   []string `yaml:"hooks"`   // Hooks the command is called for, "pages" and/or "html"
+	Timeout string   `yaml:"timeout"` // Maximum duration of a call, e.g. "10s". Defaults to 30s.
+}
+
+// Message sent to a processor
+type ProcessorRequest struct {
+	Hook  string           `json:"hook"`            // Hook the processor is called for
+	Pages []*ProcessorPage `json:"pages,omitempty"` // Every page, for the "pages" hook
+	Page  *ProcessorPage   `json:"page,omitempty"`  // The rendered page, for the "html" hook
+}
+
+// Message returned by a processor. Fields left out don't change anything.
+type ProcessorResponse struct {
+	Pages []*ProcessorPage `json:"pages,omitempty"` // Pages with an ID update the page with that ID, the others are added
+	Page  *ProcessorPage   `json:"page,omitempty"`  // The page with its new HTML, for the "html" hook
+	Error string           `json:"error,omitempty"` // Fails the build with this message
+}
+
+// A page exchanged with a processor
+type ProcessorPage struct {
+	ID        *int                   `json:"id,omitempty"`        // Identifies existing pages, leave it out to add a page
+	Name      string                 `json:"name,omitempty"`      // Page name, an empty name doesn't change it
+	Path      string                 `json:"path"`                // Page path, an empty path doesn't change it. Paths of added pages are relative to the default language.
+	Title     string                 `json:"title,omitempty"`     // Page title, an empty title doesn't change it
+	Template  string                 `json:"template,omitempty"`  // Page template, an empty template doesn't change it
+	AddToMenu bool                   `json:"addToMenu,omitempty"` // Adds the page to the menu, existing pages can't be removed from it
+	MenuGroup string                 `json:"menuGroup,omitempty"` // Menu group, an empty group doesn't change it
+	Source    string                 `json:"source,omitempty"`    // Source file relative to the root directory, read only
+	Lang      string                 `json:"lang,omitempty"`      // Language code, read only
+	Params    map[string]interface{} `json:"params,omitempty"`    // Extra front matter keys
+	Markdown  *string                `json:"markdown,omitempty"`  // Markdown source with the front matter stripped
+	HTML      *string                `json:"html,omitempty"`      // Rendered page, for the "html" hook
+}
+
+// Calls a processor command from the build hooks
+type processorPlugin struct {
+	BasePlugin
+	config  *ProcessorConfig
+	rootDir string
+	timeout time.Duration
+	hooks   map[string]bool
+}
+
+func newProcessorPlugin(c *ProcessorConfig, rootDir string) *processorPlugin {
+	return &processorPlugin{
+		config:  c,
+		rootDir: rootDir,
+	}
+}
+
+func (pp *processorPlugin) Name() string {
+	return "processor " + pp.name()
+}
+
+// Returns the configured name, or the command name
+func (pp *processorPlugin) name() string {
+	if pp.config.Name == "" && len(pp.config.Command) > 0 {
+		return filepath.Base(pp.config.Command[0])
+	}
+
+	return pp.config.Name
+}
+
+// Validates the processor config
+func (pp *processorPlugin) ConfigLoaded(c *ParserConfig) error {
+	if len(pp.config.Command) == 0 || pp.config.Command[0] == "" {
+		return errors.New("no command is configured")
+	}
+
+	pp.timeout = DefaultProcessorTimeout
+
+	if pp.config.Timeout != "" {
+		d, err := time.ParseDuration(pp.config.Timeout)
+
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", pp.config.Timeout)
+		}
+
+		pp.timeout = d
+	}
+
+	pp.hooks = make(map[string]bool)
+
+	for _, h := range pp.config.Hooks {
+		if h != ProcessorHookPages && h != ProcessorHookHTML {
+			return fmt.Errorf("unknown hook %q, expected %q or %q", h, ProcessorHookPages, ProcessorHookHTML)
+		}
+
+		pp.hooks[h] = true
+	}
+
+	if len(pp.hooks) == 0 {
+		return errors.New("no hooks are configured")
+	}
+
+	return nil
+}
+
+// Sends every page to the processor, then applies the changes and adds the new pages
+func (pp *processorPlugin) FilesLoaded(p *Parser) error {
+	if !pp.hooks[ProcessorHookPages] {
+		return nil
+	}
+
+	req := ProcessorRequest{
+		Hook:  ProcessorHookPages,
+		Pages: make([]*ProcessorPage, len(p.Files)),
+	}
+
+	for i, f := range p.Files {
+		id := i
+		markdown := string(f.content)
+
+		req.Pages[i] = &ProcessorPage{
+			ID:       &id,
+			Path:     f.Path,
+			Title:    f.Title,
+			Source:   pp.relPath(f.SourceFile),
+			Params:   jsonParams(f.Params),
+			Markdown: &markdown,
+		}
+
+		if f.lang != nil {
+			req.Pages[i].Lang = f.lang.Code
+		}
+	}
+
+	res, err := pp.call(&req)
+
+	if err != nil {
+		return err
+	}
+
+	added := 0
+
+	for _, pg := range res.Pages {
+		if pg.ID == nil {
+			p.Files = append(p.Files, pg.newFile())
+			added++
+			continue
+		}
+
+		if *pg.ID < 0 || *pg.ID >= len(req.Pages) {
+			return fmt.Errorf("unknown page ID %d", *pg.ID)
+		}
+
+		pg.apply(p.Files[*pg.ID])
+	}
+
+	if added > 0 {
+		log.Infof("%s added %d pages", pp.Name(), added)
+	}
+
+	return nil
+}
+
+// Sends the rendered page to the processor and returns its new HTML
+func (pp *processorPlugin) TransformHTML(ctx *RenderContext, html []byte) ([]byte, error) {
+	if !pp.hooks[ProcessorHookHTML] {
+		return html, nil
+	}
+
+	content := string(html)
+	req := ProcessorRequest{
+		Hook: ProcessorHookHTML,
+		Page: &ProcessorPage{
+			Path:   ctx.pagePath,
+			Title:  ctx.Title,
+			Source: pp.relPath(ctx.sourceFile),
+			Params: jsonParams(ctx.Params),
+			HTML:   &content,
+		},
+	}
+
+	if ctx.Lang != nil {
+		req.Page.Lang = ctx.Lang.Code
+	}
+
+	res, err := pp.call(&req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Page == nil || res.Page.HTML == nil {
+		return html, nil
+	}
+
+	return []byte(*res.Page.HTML), nil
+}
+
+// Writes the hashes of the command and of the arguments that are files to w, so changing a processor script
+// rebuilds every page. Paths are resolved from the root directory, a command without a path is looked up in PATH.
+func (c *ProcessorConfig) writeHashes(w io.Writer, rootDir string) {
+	for i, arg := range c.Command {
+		fp := arg
+
+		if i == 0 && !strings.ContainsRune(arg, '/') && !strings.ContainsRune(arg, filepath.Separator) {
+			var err error
+
+			if fp, err = exec.LookPath(arg); err != nil {
+				continue
+			}
+		} else if !filepath.IsAbs(fp) {
+			fp = filepath.Join(rootDir, fp)
+		}
+
+		if info, err := os.Stat(fp); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		f, err := os.Open(fp)
+
+		if err != nil {
+			continue
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		_ = f.Close()
+
+		if err == nil {
+			fmt.Fprintf(w, "processor %s %x\n", arg, h.Sum(nil))
+		}
+	}
+}
+
+// Runs the command with the request on stdin and returns its response
+func (pp *processorPlugin) call(req *ProcessorRequest) (*ProcessorResponse, error) {
+	data, err := json.Marshal(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode request: %s", err.Error())
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), pp.timeout)
+	defer cancelFn()
+
+	stdout := bytes.NewBuffer(make([]byte, 0))
+	stderr := bytes.NewBuffer(make([]byte, 0))
+
+	cmd := exec.CommandContext(ctx, pp.config.Command[0], pp.config.Command[1:]...)
+	cmd.Dir = pp.rootDir
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s hook timed out after %s%s", req.Hook, pp.timeout, stderrSuffix(stderr.Bytes()))
+	} else if err != nil {
+		return nil, fmt.Errorf("%s hook failed: %s%s", req.Hook, err.Error(), stderrSuffix(stderr.Bytes()))
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		if line != "" {
+			log.WithField("processor", pp.name()).Info(line)
+		}
+	}
+
+	var res ProcessorResponse
+
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, fmt.Errorf("%s hook returned invalid JSON: %s", req.Hook, err.Error())
+	}
+
+	if res.Error != "" {
+		return nil, fmt.Errorf("%s hook failed: %s", req.Hook, res.Error)
+	}
+
+	return &res, nil
+}
+
+// Returns path relative to the root directory
+func (pp *processorPlugin) relPath(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	if rel, err := filepath.Rel(pp.rootDir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+
+	return path
+}
+
+// Returns the end of the command output, to be appended to an error
+func stderrSuffix(stderr []byte) string {
+	s := strings.TrimSpace(string(stderr))
+
+	if s == "" {
+		return ""
+	}
+
+	if len(s) > processorStderrLimit {
+		s = "..." + s[len(s)-processorStderrLimit:]
+	}
+
+	return "\n\t" + strings.Replace(s, "\n", "\n\t", -1)
+}
+
+// Returns the front matter params with the YAML maps converted so they can be encoded as JSON
+func jsonParams(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+
+	return jsonCompatible(params).(map[string]interface{})
+}
+
+// Applies the changes made by a processor to an existing page. The link index and the menus are built
+// from the pages when the renderer is created, so they include the new paths, names and groups.
+func (pg *ProcessorPage) apply(f *File) {
+	if pg.Path != "" {
+		f.Path = pg.Path
+	}
+
+	if pg.Name != "" {
+		f.Name = pg.Name
+	}
+
+	if pg.Template != "" {
+		f.Template = pg.Template
+	}
+
+	if pg.MenuGroup != "" {
+		f.MenuGroup = pg.MenuGroup
+	}
+
+	if pg.AddToMenu {
+		f.AddToMenu = true
+	}
+
+	changed := false
+
+	if pg.Title != "" && pg.Title != f.Title {
+		f.Title = pg.Title
+		changed = true
+	}
+
+	if pg.Params != nil {
+		f.Params = pg.Params
+		changed = true
+	}
+
+	if pg.Markdown != nil && *pg.Markdown != string(f.content) {
+		f.content = []byte(*pg.Markdown)
+		changed = true
+	}
+
+	if changed {
+		// the page no longer only depends on its source file
+		params, _ := json.Marshal(f.Params)
+		f.hash = hashBytes([]byte(f.sourceHash()), []byte(f.Title), params, f.content)
+	}
+}
+
+// Returns the page added by a processor
+func (pg *ProcessorPage) newFile() *File {
+	markdown := ""
+
+	if pg.Markdown != nil {
+		markdown = *pg.Markdown
+	}
+
+	f := NewGeneratedFile(BasePage{
+		Name:      pg.Name,
+		Path:      pg.Path,
+		Template:  pg.Template,
+		AddToMenu: pg.AddToMenu,
+		MenuGroup: pg.MenuGroup,
+	}, pg.Title, []byte(markdown))
+
+	if pg.Params != nil {
+		f.Params = pg.Params
+	}
+
+	return f
+}
@@ -0,0 +1,83 @@
+package zmdocs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessorChangesPagePath(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	rootDir, err := ioutil.TempDir("", "zmdocs")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range map[string]string{
+		"index.md": "# Home\n\n[Setup](setup.md)\n",
+		"setup.md": "# Setup\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(rootDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outDir := filepath.Join(rootDir, "out")
+	p := NewParser(&ParserConfig{
+		RootDir: rootDir,
+		OutDir:  outDir,
+		Pages: []*Page{
+			{BasePage: BasePage{SourceFile: "index.md", Path: "/"}},
+			{BasePage: BasePage{SourceFile: "setup.md", Path: "setup"}},
+		},
+		MenuItems: []*MenuItem{{Name: "guide", Title: "Guide", Group: true}},
+		Processors: []*ProcessorConfig{{
+			Command: []string{"sh", "-c", `cat > /dev/null; echo '{"pages": [{"id": 1, "path": "guide/setup", "name": "setup", "menuGroup": "guide", "addToMenu": true}]}'`},
+			Hooks:   []string{ProcessorHookPages},
+		}},
+	})
+
+	if err := p.LoadSourceFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	rnd, err := p.Renderer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewMemFS()
+	rnd.FS = fs
+
+	if err := rnd.Render(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat(filepath.Join(outDir, "guide", "setup", "index.html")); err != nil {
+		t.Errorf("expected the page to be written to its new path: %s", err)
+	}
+
+	data, err := fs.ReadFile(filepath.Join(outDir, "index.html"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `<a href="guide/setup/">Setup</a>`) {
+		t.Errorf("expected the link to point at the new path, got:\n%s", data)
+	}
+
+	if items := p.Config.MenuItems[0].Items; len(items) != 1 || items[0].Link != "guide/setup" {
+		t.Errorf("expected the page in the guide menu group, got %+v", items)
+	}
+}
@@ -3,21 +3,43 @@ package zmdocs
 import (
 	"bytes"
 	"fmt"
+	"github.com/russross/blackfriday"
 	"github.com/sirupsen/logrus"
 	"github.com/zyra/zmdocs/templates"
+	"html"
 	"html/template"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"text/template/parse"
+	"time"
 )
 
 // Renderer contains all the relevant data to render the docs
 type Renderer struct {
-	MenuItems []*MenuItem
-	Contexts  []*RenderContext
-	Templates []*Template
+	MenuItems   []*MenuItem
+	Contexts    []*RenderContext
+	Templates   []*Template
+	PartialsDir string
+	OutDir      string // Output directory, used to clean up stale files
+	CleanOutDir bool   // Whether to delete files in OutDir that were not produced by this render
+	Highlight   *HighlightConfig
+	Assets      []*Asset // Static files copied to the output directory
+	Search      *SearchConfig
+	Sitemap     *SitemapConfig
+	Robots      *RobotsConfig
+	BaseURL     string
+	RootDir     string
+	Languages   []*Language // Configured languages, the site root redirects to the default one
+	Jobs        int         // Number of pages rendered in parallel, defaults to the number of CPUs
+	FS          OutputFS    // File system the site is written to, defaults to the disk
+	Plugins     []Plugin    // Plugins called while rendering, after the built-in ones
+
+	written map[string]bool
 }
 
+const baseTemplateName = "base"
+
 // Renders all all render contexts and outputs their files
 func (r *Renderer) Render() error {
 	log.WithFields(logrus.Fields{
@@ -26,14 +48,201 @@ func (r *Renderer) Render() error {
 		"templates": len(r.Templates),
 	}).Infof("rendering")
 
+	tmpls, tmplHashes, err := r.loadTemplates()
+
+	if err != nil {
+		return err
+	}
+
+	log.Debug("starting render process")
+
+	for _, ctx := range r.Contexts {
+		ctx.templateName = ctx.Template
+
+		if _, ok := tmpls[ctx.templateName]; !ok {
+			if ctx.Template != "" && ctx.Template != baseTemplateName {
+				ctx.l.Warnf("template %s was not found, using the base template", ctx.Template)
+			}
+
+			ctx.templateName = baseTemplateName
+		}
+
+		ctx.inputs.Template = tmplHashes[ctx.templateName]
+	}
+
+	prev := readManifest(r.fs(), r.OutDir)
+	manifest := &BuildManifest{
+		Version: manifestVersion,
+		Files:   make(map[string]*ManifestEntry),
+	}
+	skipped := make([]bool, len(r.Contexts))
+	plugins := r.allPlugins()
+
+	err = forEachParallel(r.Jobs, len(r.Contexts), func(i int) error {
+		ctx := r.Contexts[i]
+		tmpl := tmpls[ctx.templateName]
+
+		ctx.fs = r.fs()
+		ctx.plugins = plugins
+
+		if prev.unchanged(ctx.fs, r.OutDir, ctx.OutFile, &ctx.inputs) {
+			ctx.l.Debug("page is unchanged, skipping")
+			skipped[i] = true
+		} else if err := ctx.Render(tmpl); err != nil {
+			return fmt.Errorf("unable to render %s: %s", ctx.Link, err.Error())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to render pages: %s", err.Error())
+	}
+
+	unchanged := 0
+
+	for i, ctx := range r.Contexts {
+		if skipped[i] {
+			unchanged++
+		}
+
+		manifest.set(r.OutDir, ctx.OutFile, &ctx.inputs)
+		r.track(ctx.OutFile)
+	}
+
+	log.Infof("rendered %d pages, %d unchanged", len(r.Contexts)-unchanged, unchanged)
+
+	if path, err := manifest.write(r.fs(), r.OutDir); err != nil {
+		return fmt.Errorf("unable to write build manifest: %s", err.Error())
+	} else {
+		r.track(path)
+	}
+
+	for _, a := range r.Assets {
+		if path, err := a.copy(r.fs(), r.OutDir); err != nil {
+			return fmt.Errorf("unable to copy asset %s: %s", a.SourceFile, err.Error())
+		} else {
+			r.track(path)
+		}
+	}
+
+	if len(r.Assets) > 0 {
+		log.Infof("copied %d assets", len(r.Assets))
+	}
+
+	site := &Site{
+		Pages:   r.Contexts,
+		OutDir:  r.OutDir,
+		BaseURL: r.BaseURL,
+		FS:      r.fs(),
+		r:       r,
+	}
+
+	for _, pl := range plugins {
+		if err := pl.AfterRender(site); err != nil {
+			return fmt.Errorf("%s: %s", pl.Name(), err.Error())
+		}
+	}
+
+	if _, disk := r.fs().(DiskFS); r.CleanOutDir && disk {
+		if err := r.cleanOutDir(); err != nil {
+			return fmt.Errorf("unable to clean output directory: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Returns the file system the site is written to
+func (r *Renderer) fs() OutputFS {
+	if r.FS == nil {
+		return DiskFS{}
+	}
+
+	return r.FS
+}
+
+// Writes an index.html to dir that redirects to target. Returns the written file path.
+func writeRedirect(fs OutputFS, dir, target string) (string, error) {
+	target = html.EscapeString(target)
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="UTF-8"><meta http-equiv="refresh" content="0; url=%s"><link rel="canonical" href="%s"></head><body><a href="%s">%s</a></body></html>`, target, target, target, target)
+	dest := filepath.Join(dir, "index.html")
+
+	return dest, fs.WriteFile(dest, []byte(page), time.Time{})
+}
+
+// Marks a file as produced by the current render
+func (r *Renderer) track(path string) {
+	if r.written == nil {
+		r.written = make(map[string]bool)
+	}
+
+	r.written[filepath.Clean(path)] = true
+}
+
+// Removes files and empty directories in the output directory that were not produced by the current render
+func (r *Renderer) cleanOutDir() error {
+	if r.OutDir == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(r.OutDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	dirs := make([]string, 0)
+	removed := 0
+
+	err := filepath.Walk(r.OutDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != r.OutDir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+
+		if r.written[filepath.Clean(path)] {
+			return nil
+		}
+
+		log.Debugf("removing stale file %s", path)
+		removed++
+
+		return os.Remove(path)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// remove deepest directories first so parents can become empty
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := ioutil.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			_ = os.Remove(dirs[i])
+		}
+	}
+
+	log.Infof("removed %d stale files", removed)
+
+	return nil
+}
+
+// Loads all template sets. Every set contains the base layout and the partials,
+// page templates are parsed on top of those so they can override `{{block}}`s defined in the layout.
+// Also returns the hash of the sources of every set.
+func (r *Renderer) loadTemplates() (map[string]*template.Template, map[string]string, error) {
 	var baseTemplateStr string
 
 	for _, t := range r.Templates {
-		if t.Name == "base" {
+		if t.Name == baseTemplateName {
 			baseTemplateData, err := ioutil.ReadFile(t.SourceFile)
 
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 
 			baseTemplateStr = string(baseTemplateData)
@@ -45,43 +254,156 @@ func (r *Renderer) Render() error {
 		baseTemplateStr = templates.BaseTemplate
 	}
 
-	baseTemplate, err := template.New("").Parse(baseTemplateStr)
+	baseHash := []byte(baseTemplateStr)
+	baseTemplate, err := template.New(baseTemplateName).Parse(baseTemplateStr)
 
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("unable to parse base template: %s", err.Error())
 	}
 
-	log.Debug("starting render process")
+	if partials, err := r.partialFiles(); err != nil {
+		return nil, nil, err
+	} else if len(partials) > 0 {
+		log.Debugf("loading %d partials", len(partials))
 
-	for _, ctx := range r.Contexts {
-		if err := ctx.Render(baseTemplate); err != nil {
-			return fmt.Errorf("unable to render page: %s", err.Error())
+		for _, pf := range partials {
+			data, err := ioutil.ReadFile(pf)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			baseHash = append(baseHash, hashBytes([]byte(pf), data)...)
+		}
+
+		if baseTemplate, err = baseTemplate.ParseFiles(partials...); err != nil {
+			return nil, nil, fmt.Errorf("unable to parse partials: %s", err.Error())
 		}
 	}
 
-	log.Infof("rendered %d pages", len(r.Contexts))
+	tmpls := map[string]*template.Template{
+		baseTemplateName: baseTemplate,
+	}
+	hashes := map[string]string{
+		baseTemplateName: hashBytes(baseHash),
+	}
 
-	return nil
+	for _, t := range r.Templates {
+		if t.Name == baseTemplateName {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(t.SourceFile)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		set, err := baseTemplate.Clone()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pt, err := set.New(t.Name).Parse(string(data))
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse template %s: %s", t.Name, err.Error())
+		}
+
+		hashes[t.Name] = hashBytes([]byte(hashes[baseTemplateName]), data)
+
+		if pt.Tree == nil || parse.IsEmptyTree(pt.Tree.Root) {
+			// the template only overrides blocks, render it through the base layout
+			tmpls[t.Name] = set.Lookup(baseTemplateName)
+		} else {
+			tmpls[t.Name] = pt
+		}
+	}
+
+	return tmpls, hashes, nil
+}
+
+// Returns all files found in the partials directory
+func (r *Renderer) partialFiles() ([]string, error) {
+	files := make([]string, 0)
+
+	if r.PartialsDir == "" {
+		return files, nil
+	}
+
+	if _, err := os.Stat(r.PartialsDir); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := filepath.Walk(r.PartialsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read partials directory: %s", err.Error())
+	}
+
+	return files, nil
 }
 
 // A render context contains all required information to render a single page
 type RenderContext struct {
-	Title       string
-	SiteTitle   string
-	Description string
-	MenuItems   []*MenuItem
-	Content     template.HTML
-	OutDir      string
-	OutFile     string
-	BaseURL     string
-	Link        string
+	Title        string
+	SiteTitle    string
+	Description  string
+	MenuItems    []*MenuItem
+	Content      template.HTML
+	OutDir       string
+	OutFile      string
+	BaseURL      string
+	Link         string
+	Params       map[string]interface{}
+	Template     string
+	EditURL      string // Link to edit the page source on the repo host, only set if the page has "editOnGithub" enabled
+	IssueURL     string // Link to report an issue about the page on the repo host
+	Stylesheets  []string
+	TOC          []*TOCEntry    // Table of contents of the page
+	RootURL      string         // Link to the site root, ends with a slash
+	SearchIndex  string         // Path of the search index manifest relative to the site root, empty if search is disabled
+	Version      string         // Name of the version being built, empty if the docs are not versioned
+	Versions     []*VersionLink // All versions, for the version switcher
+	Outdated     bool           // Whether the version being built is not the latest version
+	LatestURL    string         // Link to the latest version
+	Lang         *Language      // Language of the page, nil if no languages are configured
+	Translations []*Translation // The page in every language it is available in, for the language switcher
+	Untranslated bool           // Whether the page is shown in the default language because it has no translation
 
-	l *logrus.Entry
+	search       *SearchDocument
+	doc          *blackfriday.Node    // Parsed markdown, rendered to Content when the page is rendered
+	rnd          blackfriday.Renderer // Markdown renderer
+	inputs       ManifestEntry        // Hashes of the page inputs
+	templateName string               // Template the page is rendered with, set by Renderer.Render
+	fs           OutputFS             // File system the page is written to, defaults to the disk
+	plugins      []Plugin             // Plugins transforming the page HTML, set by Renderer.Render
+	pagePath     string
+	sourceFile   string
+	noSitemap    bool
+	l            *logrus.Entry
 }
 
 // Returns a new render context from the provided file, parser config, and HTML content
 func NewRenderContext(f *File, c *ParserConfig, content template.HTML) *RenderContext {
-	outDir := filepath.Join(c.RootDir, "docs", f.Path)
+	baseOutDir := c.OutDir
+
+	if baseOutDir == "" {
+		baseOutDir = filepath.Join(c.RootDir, DefaultOutDir)
+	}
+
+	outDir := filepath.Join(baseOutDir, f.Path)
 	outFile := filepath.Join(outDir, "index.html")
 
 	ctx := RenderContext{
@@ -94,6 +416,17 @@ func NewRenderContext(f *File, c *ParserConfig, content template.HTML) *RenderCo
 		OutFile:     outFile,
 		BaseURL:     c.BaseURL,
 		Link:        f.Path,
+		Params:      f.Params,
+		Template:    f.Template,
+		IssueURL:    c.IssueURL(f.Title),
+		Stylesheets: make([]string, 0),
+		pagePath:    f.Path,
+		sourceFile:  f.SourceFile,
+		noSitemap:   f.NoSitemap,
+	}
+
+	if f.EditOnGithub {
+		ctx.EditURL = c.EditURL(f.SourceFile)
 	}
 
 	ctx.l = log.WithFields(logrus.Fields{
@@ -104,22 +437,22 @@ func NewRenderContext(f *File, c *ParserConfig, content template.HTML) *RenderCo
 	return &ctx
 }
 
+// Returns the translation of s in the page language, for use in templates: `{{ .T "On this page" }}`
+func (c *RenderContext) T(s string) string {
+	return c.Lang.T(s)
+}
+
 // Renders and outputs the page
 func (c *RenderContext) Render(tmpl *template.Template) error {
 	c.l.Debug("rendering page")
 
-	for _, mit := range c.MenuItems {
-		if mit.Group {
-			for _, cmit := range mit.Items {
-				cmit.Active = cmit.Link == c.Link
-			}
-		} else if mit.Link == c.Link {
-			mit.Active = true
-		} else {
-			mit.Active = false
-		}
+	if c.Content == "" && c.doc != nil {
+		c.Content = template.HTML(renderMarkdown(c.rnd, c.doc))
 	}
 
+	// the menu is shared by all pages, every page gets its own copy with the active item set
+	c.MenuItems = activeMenu(c.MenuItems, c.Link, c.RootURL)
+
 	if tmpl != nil {
 		buff := bytes.NewBuffer(make([]byte, 0))
 
@@ -130,15 +463,50 @@ func (c *RenderContext) Render(tmpl *template.Template) error {
 		c.Content = template.HTML(buff.String())
 	}
 
-	if err := os.MkdirAll(c.OutDir, 0755); err != nil {
-		return fmt.Errorf("unable to create directory: %s", err.Error())
+	html, err := c.transformHTML([]byte(c.Content))
+
+	if err != nil {
+		return err
+	}
+
+	fs := c.fs
+
+	if fs == nil {
+		fs = DiskFS{}
 	}
 
 	c.l.Debugf("writing file to %s", c.OutFile)
 
-	if err := ioutil.WriteFile(c.OutFile, []byte(c.Content), 0644); err != nil {
+	if err := fs.WriteFile(c.OutFile, html, time.Time{}); err != nil {
 		return fmt.Errorf("unable to write file: %s", err.Error())
 	}
 
 	return nil
 }
+
+// Returns a copy of the menu with the items linking to link marked as active. Page path links are
+// prefixed with rootURL, the link to the site root from the page.
+func activeMenu(items []*MenuItem, link, rootURL string) []*MenuItem {
+	out := make([]*MenuItem, len(items))
+
+	for i, it := range items {
+		mit := *it
+		mit.Active = !it.Group && it.Link == link
+
+		if mit.pagePath {
+			if pp := normalizePagePath(it.Link); pp != "" {
+				mit.Link = rootURL + pp + "/"
+			} else {
+				mit.Link = rootURL
+			}
+		}
+
+		if it.Items != nil {
+			mit.Items = activeMenu(it.Items, link, rootURL)
+		}
+
+		out[i] = &mit
+	}
+
+	return out
+}
@@ -0,0 +1,289 @@
+package zmdocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/russross/blackfriday"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Directory the search index is written to when none is configured
+const DefaultSearchPath = "search"
+
+// Search index configuration
+type SearchConfig struct {
+	Disabled    bool   `yaml:"disabled"`    // Don't generate the search index
+	ShardPrefix int    `yaml:"shardPrefix"` // Number of leading characters of the terms the index is sharded by, 0 writes a single shard
+	Path        string `yaml:"path"`        // Directory of the index files relative to the output directory, defaults to "search"
+}
+
+// Returns the path of the index manifest relative to the output directory, empty if search is disabled
+func (c *SearchConfig) IndexPath() string {
+	if c != nil && c.Disabled {
+		return ""
+	}
+
+	return path.Join(c.dir(), "index.json")
+}
+
+func (c *SearchConfig) dir() string {
+	if c == nil || c.Path == "" {
+		return DefaultSearchPath
+	}
+
+	return strings.Trim(filepath.ToSlash(c.Path), "/")
+}
+
+// Searchable content of a page
+type SearchDocument struct {
+	Title    string   `json:"title"`
+	Link     string   `json:"link"` // Page path relative to the site root
+	Headings []string `json:"headings"`
+	Text     string   `json:"text"` // Page text without markup
+}
+
+// Search index manifest. Browsers only download the shards of the prefixes of the searched terms.
+type SearchManifest struct {
+	Pages       string            `json:"pages"`       // Path of the list of indexed pages relative to the site root
+	ShardPrefix int               `json:"shardPrefix"` // Number of leading characters of the terms the shards are keyed by
+	Shards      map[string]string `json:"shards"`      // Shard paths relative to the site root, by term prefix
+}
+
+// Page listed in the search index, postings refer to pages by their position in the list
+type searchPage struct {
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+// Occurrences of a term in a page: the page position, then the number of occurrences in the title, headings and text
+type searchPosting [4]int
+
+// Postings of the terms in a shard, by term
+type searchShard map[string][]searchPosting
+
+// Builds the search document of a page from its parsed markdown
+func NewSearchDocument(title, link string, doc *blackfriday.Node) *SearchDocument {
+	sd := SearchDocument{
+		Title:    title,
+		Link:     link,
+		Headings: make([]string, 0),
+	}
+
+	text := make([]string, 0)
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+
+		switch node.Type {
+		case blackfriday.Heading:
+			if h := nodeText(node); h != "" && h != title {
+				sd.Headings = append(sd.Headings, h)
+			}
+			return blackfriday.SkipChildren
+		case blackfriday.Text, blackfriday.Code, blackfriday.CodeBlock:
+			if t := strings.TrimSpace(string(node.Literal)); t != "" {
+				text = append(text, t)
+			}
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	sd.Text = strings.Join(strings.Fields(strings.Join(text, " ")), " ")
+
+	return &sd
+}
+
+// Writes the search index for the provided documents under the prefix directory. Returns the paths of all written files.
+func (c *SearchConfig) writeIndex(fs OutputFS, outDir, prefix string, docs []*SearchDocument) ([]string, error) {
+	indexPath := c.IndexPath()
+
+	if indexPath == "" {
+		return nil, nil
+	}
+
+	indexPath = path.Join(prefix, indexPath)
+
+	shardPrefix := 0
+
+	if c != nil && c.ShardPrefix > 0 {
+		shardPrefix = c.ShardPrefix
+	}
+
+	pages := make([]*searchPage, len(docs))
+	shards := make(map[string]searchShard)
+
+	for i, d := range docs {
+		pages[i] = &searchPage{Title: d.Title, Link: d.Link}
+
+		counts := make(map[string]*searchPosting)
+
+		count := func(field int, s string) {
+			for _, term := range searchTerms(s) {
+				if counts[term] == nil {
+					counts[term] = &searchPosting{i}
+				}
+
+				counts[term][field]++
+			}
+		}
+
+		count(1, d.Title)
+		count(2, strings.Join(d.Headings, " "))
+		count(3, d.Text)
+
+		for term, posting := range counts {
+			key := termPrefix(term, shardPrefix)
+
+			if shards[key] == nil {
+				shards[key] = make(searchShard)
+			}
+
+			shards[key][term] = append(shards[key][term], *posting)
+		}
+	}
+
+	if len(shards) == 0 {
+		shards[""] = make(searchShard)
+	}
+
+	keys := make([]string, 0, len(shards))
+
+	for key := range shards {
+		keys = append(keys, key)
+	}
+
+	// shards are numbered in prefix order so the file names are stable between builds
+	sort.Strings(keys)
+
+	written := make([]string, 0)
+	manifest := SearchManifest{
+		Pages:       path.Join(prefix, c.dir(), "pages.json"),
+		ShardPrefix: shardPrefix,
+		Shards:      make(map[string]string),
+	}
+
+	if file, err := writeJSON(fs, outDir, manifest.Pages, pages); err != nil {
+		return nil, err
+	} else {
+		written = append(written, file)
+	}
+
+	for i, key := range keys {
+		shard := path.Join(prefix, c.dir(), fmt.Sprintf("shard-%d.json", i))
+
+		if file, err := writeJSON(fs, outDir, shard, shards[key]); err != nil {
+			return nil, err
+		} else {
+			written = append(written, file)
+		}
+
+		manifest.Shards[key] = shard
+	}
+
+	if file, err := writeJSON(fs, outDir, indexPath, manifest); err != nil {
+		return nil, err
+	} else {
+		written = append(written, file)
+	}
+
+	return written, nil
+}
+
+// Splits s into lowercase terms of letters and digits, the same way the search script splits queries
+func searchTerms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Returns the first n characters of the term, the whole term if it's shorter
+func termPrefix(term string, n int) string {
+	runes := []rune(term)
+
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+
+	return term
+}
+
+func writeJSON(fs OutputFS, outDir, file string, v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(outDir, filepath.FromSlash(file))
+
+	return dest, fs.WriteFile(dest, data, time.Time{})
+}
+
+// Writes the search index once the pages are rendered
+type searchPlugin struct {
+	BasePlugin
+}
+
+func (searchPlugin) Name() string {
+	return "search"
+}
+
+// Writes the search index for all pages, one index per language
+func (searchPlugin) AfterRender(s *Site) error {
+	prefixes := make([]string, 0)
+	docs := make(map[string][]*SearchDocument)
+	indexed := 0
+
+	for _, ctx := range s.Pages {
+		if ctx.search == nil {
+			continue
+		}
+
+		prefix := ""
+
+		if ctx.Lang != nil {
+			prefix = ctx.Lang.Code
+		}
+
+		if _, ok := docs[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+		}
+
+		docs[prefix] = append(docs[prefix], ctx.search)
+		indexed++
+	}
+
+	if len(prefixes) == 0 {
+		prefixes = append(prefixes, "")
+	}
+
+	written := 0
+
+	for _, prefix := range prefixes {
+		files, err := s.r.Search.writeIndex(s.FS, s.OutDir, prefix, docs[prefix])
+
+		if err != nil {
+			return fmt.Errorf("unable to write search index: %s", err.Error())
+		}
+
+		for _, f := range files {
+			s.r.track(f)
+		}
+
+		written += len(files)
+	}
+
+	if written > 0 {
+		log.Infof("indexed %d pages for search", indexed)
+	}
+
+	return nil
+}
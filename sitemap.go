@@ -0,0 +1,215 @@
+package zmdocs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sources for the sitemap lastmod value
+const (
+	LastModMtime = "mtime"
+	LastModGit   = "git"
+	LastModNone  = "none"
+)
+
+// sitemap.xml configuration
+type SitemapConfig struct {
+	Enabled bool     `yaml:"enabled"` // Write sitemap.xml to the output directory, requires baseUrl
+	LastMod string   `yaml:"lastmod"` // Where the lastmod value comes from: "mtime" (default), "git" or "none"
+	Exclude []string `yaml:"exclude"` // Globs of page paths to leave out, pages can also set `sitemap: false` in their front matter
+
+	history *gitHistory // Repository the sources were exported from, the git lastmod is read from there
+}
+
+// Git repository the sources were exported from. Exports have no .git directory, so their history is read from the repository.
+type gitHistory struct {
+	repoDir string // Root of the repository
+	ref     string // Ref the sources were exported from
+	treeDir string // Directory the tree of ref was exported to
+}
+
+// robots.txt configuration
+type RobotsConfig struct {
+	Enabled   bool     `yaml:"enabled"`   // Write robots.txt to the output directory
+	UserAgent string   `yaml:"userAgent"` // Defaults to "*"
+	Allow     []string `yaml:"allow"`     // Allowed paths
+	Disallow  []string `yaml:"disallow"`  // Disallowed paths
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Returns the absolute URL of a page
+func absolutePageURL(baseURL, pagePath string) string {
+	base := strings.TrimSuffix(baseURL, "/") + "/"
+	pagePath = normalizePagePath(pagePath)
+
+	if pagePath == "" {
+		return base
+	}
+
+	return base + pagePath + "/"
+}
+
+// Returns whether the page should be listed in the sitemap
+func (c *SitemapConfig) includes(ctx *RenderContext) bool {
+	if ctx.noSitemap {
+		return false
+	}
+
+	for _, ex := range c.Exclude {
+		if MatchGlob(ex, normalizePagePath(ctx.pagePath)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the last modification time of the source file, empty if unknown
+func (c *SitemapConfig) lastMod(rootDir, sourceFile string) string {
+	if sourceFile == "" {
+		return ""
+	}
+
+	switch c.LastMod {
+	case LastModNone:
+		return ""
+
+	case LastModGit:
+		dir, args := rootDir, []string{"log", "-1", "--format=%cI", "--", sourceFile}
+
+		if h := c.history; h != nil {
+			// paths in the export are the same as in the repository
+			if rel, err := filepath.Rel(h.treeDir, sourceFile); err == nil {
+				dir = h.repoDir
+				args = []string{"log", "-1", "--format=%cI", h.ref, "--", filepath.ToSlash(rel)}
+			}
+		}
+
+		if out, err := git(dir, args...); err == nil && len(bytes.TrimSpace(out)) > 0 {
+			return string(bytes.TrimSpace(out))
+		}
+
+		// files that aren't committed yet fall back to the modification time
+		fallthrough
+
+	default:
+		if info, err := os.Stat(sourceFile); err == nil {
+			return info.ModTime().UTC().Format(time.RFC3339)
+		}
+	}
+
+	return ""
+}
+
+// Writes sitemap.xml for the provided pages. Returns the written file path.
+func (c *SitemapConfig) write(fs OutputFS, outDir, rootDir, baseURL string, contexts []*RenderContext) (string, error) {
+	set := sitemapURLSet{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  make([]sitemapURL, 0, len(contexts)),
+	}
+
+	for _, ctx := range contexts {
+		if !c.includes(ctx) {
+			continue
+		}
+
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     absolutePageURL(baseURL, ctx.pagePath),
+			LastMod: c.lastMod(rootDir, ctx.sourceFile),
+		})
+	}
+
+	data, err := xml.MarshalIndent(set, "", "  ")
+
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(outDir, "sitemap.xml")
+	data = append([]byte(xml.Header), data...)
+
+	return dest, fs.WriteFile(dest, data, time.Time{})
+}
+
+// Writes robots.txt, pointing at the sitemap if there is one. Returns the written file path.
+func (c *RobotsConfig) write(fs OutputFS, outDir, sitemapURL string) (string, error) {
+	buff := bytes.NewBuffer(make([]byte, 0))
+	userAgent := c.UserAgent
+
+	if userAgent == "" {
+		userAgent = "*"
+	}
+
+	fmt.Fprintf(buff, "User-agent: %s\n", userAgent)
+
+	for _, a := range c.Allow {
+		fmt.Fprintf(buff, "Allow: %s\n", a)
+	}
+
+	for _, d := range c.Disallow {
+		fmt.Fprintf(buff, "Disallow: %s\n", d)
+	}
+
+	if len(c.Allow) == 0 && len(c.Disallow) == 0 {
+		buff.WriteString("Disallow:\n")
+	}
+
+	if sitemapURL != "" {
+		fmt.Fprintf(buff, "\nSitemap: %s\n", sitemapURL)
+	}
+
+	dest := filepath.Join(outDir, "robots.txt")
+
+	return dest, fs.WriteFile(dest, buff.Bytes(), time.Time{})
+}
+
+// Writes sitemap.xml and robots.txt once the pages are rendered
+type sitemapPlugin struct {
+	BasePlugin
+}
+
+func (sitemapPlugin) Name() string {
+	return "sitemap"
+}
+
+// Writes sitemap.xml and robots.txt if they are enabled
+func (sitemapPlugin) AfterRender(s *Site) error {
+	sitemapURL := ""
+
+	if s.r.Sitemap != nil && s.r.Sitemap.Enabled {
+		if s.BaseURL == "" {
+			log.Warn("sitemap is enabled but no baseUrl is configured, skipping sitemap.xml")
+		} else if path, err := s.r.Sitemap.write(s.FS, s.OutDir, s.r.RootDir, s.BaseURL, s.Pages); err != nil {
+			return fmt.Errorf("unable to write sitemap: %s", err.Error())
+		} else {
+			s.r.track(path)
+			sitemapURL = strings.TrimSuffix(s.BaseURL, "/") + "/sitemap.xml"
+			log.Debugf("wrote sitemap to %s", path)
+		}
+	}
+
+	if s.r.Robots != nil && s.r.Robots.Enabled {
+		if path, err := s.r.Robots.write(s.FS, s.OutDir, sitemapURL); err != nil {
+			return fmt.Errorf("unable to write robots.txt: %s", err.Error())
+		} else {
+			s.r.track(path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package zmdocs
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Helper functions available in page pattern templates
+var PatternFuncs = template.FuncMap{
+	"slugify":    Slugify,
+	"title":      TitleCase,
+	"humanize":   Humanize,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       func(sep string, s []string) string { return strings.Join(s, sep) },
+	"dir":        func(s string) string { return path.Dir(toSlash(s)) },
+	"base":       func(s string) string { return path.Base(toSlash(s)) },
+	"ext":        func(s string) string { return path.Ext(toSlash(s)) },
+	"trimExt":    func(s string) string { return strings.TrimSuffix(s, path.Ext(s)) },
+	"default": func(def string, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+}
+
+// Converts a string to a lower case, URL friendly slug. e.g. "Getting Started" -> "getting-started"
+func Slugify(s string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// Upper cases the first letter of every word
+func TitleCase(s string) string {
+	prev := ' '
+
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+
+		if unicode.IsSpace(prev) || prev == '-' || prev == '_' {
+			return unicode.ToTitle(r)
+		}
+
+		return r
+	}, s)
+}
+
+// Converts slugs and file names to human readable titles. e.g. "getting-started" -> "Getting Started"
+func Humanize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return ' '
+		}
+		return r
+	}, s)
+
+	return TitleCase(strings.Join(strings.Fields(s), " "))
+}
+
+func toSlash(s string) string {
+	return strings.Replace(s, "\\", "/", -1)
+}
@@ -1,17 +1,28 @@
 package templates
 
 const BaseTemplate = `<!DOCTYPE html>
-<html lang="en">
+<html lang="{{ with .Lang }}{{ .Code }}{{ else }}en{{ end }}"{{ with .Lang }} dir="{{ .Dir }}"{{ end }}>
 <head>
     <meta charset="UTF-8">
-    <title>{{- .Title }}</title>
+    <title>{{ block "title" . }}{{- .Title }}{{ end }}</title>
     {{ if .Description }}<meta name="description" content="{{ .Description }}">{{ end }}
     {{ if .BaseURL }}<base href="{{ .BaseURL }}" />{{ end }}
     <link href="https://unpkg.com/tailwindcss@^1.0/dist/tailwind.min.css" rel="stylesheet">
     <meta name="viewport" content="width=device-width, initial-scale=1">
     <meta name="theme-color" content="#fafafa">
+    {{- range .Stylesheets }}
+    <link href="{{ . }}" rel="stylesheet">
+    {{- end }}
+    {{- block "head" . }}{{ end }}
 </head>
 <body>
+{{- define "toc" }}
+	<ul class="list-reset ml-2" role="none">
+		{{- range . }}
+		<li role="none"><a href="{{ .Href }}" class="block p-1 text-sm text-gray-600 hover:text-gray-700">{{ .Title }}</a>{{ if .Children }}{{ template "toc" .Children }}{{ end }}</li>
+		{{- end }}
+	</ul>
+{{- end }}
 <nav role="navigation">
     <div class="container flex items-center justify-between flex-wrap p-6 mx-auto">
         <div class="flex items-center flex-shrink-0 text-indigo-500 mr-6">
@@ -19,9 +30,33 @@ const BaseTemplate = `<!DOCTYPE html>
                 {{ .SiteTitle }}
             </span>
         </div>
+        {{- if .Versions }}
+        <select id="version-switcher" aria-label="{{ .T "Version" }}" class="mx-4 px-2 py-1 border border-gray-300 rounded bg-white text-gray-700"
+                onchange="location.href = this.value">
+            {{- range .Versions }}
+            <option value="{{ .Link }}"{{ if .Current }} selected{{ end }}>{{ .Name }}{{ if .Latest }} ({{ $.T "latest" }}){{ end }}</option>
+            {{- end }}
+        </select>
+        {{- end }}
+        {{- if .Translations }}
+        <select id="language-switcher" aria-label="{{ .T "Language" }}" class="mx-4 px-2 py-1 border border-gray-300 rounded bg-white text-gray-700"
+                onchange="location.href = this.value">
+            {{- range .Translations }}
+            <option value="{{ .Link }}" lang="{{ .Code }}"{{ if .Current }} selected{{ end }}>{{ .Name }}</option>
+            {{- end }}
+        </select>
+        {{- end }}
+        {{- if .SearchIndex }}
+        <div class="relative flex-grow max-w-md mx-4">
+            <input id="search-input" type="search" placeholder="{{ .T "Search" }}" autocomplete="off" aria-label="{{ .T "Search" }}"
+                   data-root="{{ .RootURL }}" data-index="{{ .SearchIndex }}" data-no-results="{{ .T "No results" }}"
+                   class="w-full px-3 py-2 rounded border border-gray-300 focus:outline-none focus:border-indigo-400">
+            <ul id="search-results" class="absolute z-10 w-full bg-white shadow-lg rounded mt-1 hidden" role="listbox"></ul>
+        </div>
+        {{- end }}
         <div class="block">
             <button id="sidenav-open-button" class="inline lg:hidden flex items-center px-3 py-2 text-indigo-400 hover:text-indigo-500">
-                <svg class="fill-current h-5 w-5" viewBox="0 0 20 20" xmlns="http://www.w3.org/2000/svg"><title>Menu</title><path d="M0 3h20v2H0V3zm0 6h20v2H0V9zm0 6h20v2H0v-2z"/></svg>
+                <svg class="fill-current h-5 w-5" viewBox="0 0 20 20" xmlns="http://www.w3.org/2000/svg"><title>{{ .T "Menu" }}</title><path d="M0 3h20v2H0V3zm0 6h20v2H0V9zm0 6h20v2H0v-2z"/></svg>
             </button>
             <button id="sidenav-close-button" class="flex items-center px-3 py-2 text-indigo-400 hover:text-indigo-500 hidden">
                 <svg class="fill-current w-5 h-5" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 20"><path d="M10 8.586L2.929 1.515 1.515 2.929 8.586 10l-7.071 7.071 1.414 1.414L10 11.414l7.071 7.071 1.414-1.414L11.414 10l7.071-7.071-1.414-1.414L10 8.586z"></path></svg>
@@ -29,6 +64,17 @@ const BaseTemplate = `<!DOCTYPE html>
         </div>
     </div>
 </nav>
+{{- if .Outdated }}
+<div class="bg-yellow-100 text-yellow-800 text-center p-3" role="alert">
+    {{ printf (.T "You are viewing the documentation for version %s, which is not the latest version.") .Version }}
+    <a href="{{ .LatestURL }}" class="underline font-semibold">{{ .T "Go to the latest version" }}</a>
+</div>
+{{- end }}
+{{- if .Untranslated }}
+<div class="bg-blue-100 text-blue-800 text-center p-3" role="note">
+    {{ .T "This page has not been translated yet." }}
+</div>
+{{- end }}
 <div class="container mx-auto">
     <div class="flex">
         <nav class="w-full lg:w-1/5 p-6 hidden lg:block" id="sidenav" role="navigation">
@@ -54,8 +100,24 @@ const BaseTemplate = `<!DOCTYPE html>
             </ul>
         </nav>
         <div class="w-full p-6" id="content-container">
-		{{ .Content }}
+		{{ block "content" . }}{{ .Content }}{{ end }}
+		{{- if or .EditURL .IssueURL }}
+			<div class="flex justify-end text-sm mt-8 pt-4 border-t border-gray-200">
+				{{- if .EditURL }}
+				<a href="{{ .EditURL }}" class="text-indigo-500 hover:text-indigo-600 ml-4" target="_blank" rel="noopener">{{ .T "Edit this page" }}</a>
+				{{- end }}
+				{{- if .IssueURL }}
+				<a href="{{ .IssueURL }}" class="text-gray-500 hover:text-gray-600 ml-4" target="_blank" rel="noopener">{{ .T "Report an issue" }}</a>
+				{{- end }}
+			</div>
+		{{- end }}
         </div>
+        {{- if .TOC }}
+        <nav class="w-1/5 p-6 hidden xl:block" id="toc" role="navigation" aria-label="{{ .T "On this page" }}">
+            <span class="font-semibold text-gray-400">{{ .T "On this page" }}</span>
+            {{ template "toc" .TOC }}
+        </nav>
+        {{- end }}
     </div>
 </div>
 
@@ -81,6 +143,115 @@ const BaseTemplate = `<!DOCTYPE html>
         });
     })();
 </script>
+{{- if .SearchIndex }}
+<script>
+    (function () {
+        const input = document.getElementById('search-input');
+        const results = document.getElementById('search-results');
+        const root = input.dataset.root;
+        let manifest = null;
+        let pages = null;
+        let loading = null;
+        const shards = {};
+
+        const tokenize = s => (s || '').toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(Boolean);
+
+        // only the manifest and the page list are loaded up front, shards are loaded for the searched terms
+        const load = () => {
+            if (!loading) {
+                loading = fetch(root + input.dataset.index)
+                    .then(res => res.json())
+                    .then(m => {
+                        manifest = m;
+                        return fetch(root + m.pages);
+                    })
+                    .then(res => res.json())
+                    .then(p => {
+                        pages = p;
+                    });
+            }
+            return loading;
+        };
+
+        // returns the prefixes of the shards that can contain terms starting with term
+        const shardKeys = term => {
+            const chars = Array.from(term);
+            if (chars.length >= manifest.shardPrefix) {
+                const key = chars.slice(0, manifest.shardPrefix).join('');
+                return manifest.shards[key] ? [key] : [];
+            }
+            return Object.keys(manifest.shards).filter(key => key.startsWith(term));
+        };
+
+        const loadShard = key => {
+            if (!shards[key]) {
+                shards[key] = fetch(root + manifest.shards[key]).then(res => res.json());
+            }
+            return shards[key];
+        };
+
+        // scores every page containing all terms, postings are [page, title, headings, text] occurrence counts
+        const rank = (terms, termShards) => {
+            let scores = null;
+            terms.forEach((term, i) => {
+                const found = new Map();
+                for (const shard of termShards[i]) {
+                    for (const token in shard) {
+                        if (!token.startsWith(term)) continue;
+                        const exact = token === term;
+                        for (const [page, title, headings, text] of shard[token]) {
+                            const r = found.get(page) || {score: 0, text: 0};
+                            r.score += title * (exact ? 12 : 8) + headings * (exact ? 5 : 3);
+                            r.text += text * (exact ? 1 : 0.5);
+                            found.set(page, r);
+                        }
+                    }
+                }
+                const next = new Map();
+                found.forEach((r, page) => {
+                    if (scores === null || scores.has(page)) {
+                        next.set(page, (scores === null ? 0 : scores.get(page)) + r.score + Math.min(r.text, 10));
+                    }
+                });
+                scores = next;
+            });
+            return Array.from(scores).sort((a, b) => b[1] - a[1]).slice(0, 10).map(([page]) => pages[page]);
+        };
+
+        const escape = s => s.replace(/[&<>"]/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;'}[c]));
+
+        const search = () => {
+            const query = input.value;
+            const terms = tokenize(query);
+            if (!pages || terms.length === 0) {
+                results.classList.add('hidden');
+                return;
+            }
+            Promise.all(terms.map(term => Promise.all(shardKeys(term).map(loadShard)))).then(termShards => {
+                // a newer query is being searched
+                if (input.value !== query) return;
+                const found = rank(terms, termShards);
+                results.innerHTML = found.length === 0
+                    ? '<li class="p-2 text-gray-500">' + escape(input.dataset.noResults) + '</li>'
+                    : found.map(d => '<li role="option"><a class="block p-2 text-gray-700 hover:bg-gray-100" href="' + escape(root + d.link) + '">' + escape(d.title) + '</a></li>').join('');
+                results.classList.remove('hidden');
+            });
+        };
+
+        input.addEventListener('focus', load);
+        input.addEventListener('input', () => load().then(search));
+        input.addEventListener('keydown', e => {
+            if (e.key === 'Escape') {
+                input.value = '';
+                results.classList.add('hidden');
+            }
+        });
+        document.addEventListener('click', e => {
+            if (e.target !== input && !results.contains(e.target)) results.classList.add('hidden');
+        });
+    })();
+</script>
+{{- end }}
 </body>
 </html>
 `
@@ -0,0 +1,142 @@
+package zmdocs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/russross/blackfriday"
+)
+
+// Heading levels included in the table of contents when none are configured
+const (
+	DefaultTOCMinLevel = 2
+	DefaultTOCMaxLevel = 3
+)
+
+// Table of contents configuration, can be set globally in the config and per page in the front matter
+type TOCConfig struct {
+	Disabled *bool `yaml:"disabled"` // Don't generate a table of contents. Left unset, the global setting applies.
+	MinLevel int   `yaml:"minLevel"` // Lowest heading level to include, defaults to 2
+	MaxLevel int   `yaml:"maxLevel"` // Highest heading level to include, defaults to 3
+}
+
+// Returns the config with the fields set in override applied over c
+func (c *TOCConfig) merge(override *TOCConfig) *TOCConfig {
+	out := TOCConfig{
+		MinLevel: DefaultTOCMinLevel,
+		MaxLevel: DefaultTOCMaxLevel,
+	}
+
+	for _, tc := range []*TOCConfig{c, override} {
+		if tc == nil {
+			continue
+		}
+
+		if tc.Disabled != nil {
+			out.Disabled = tc.Disabled
+		}
+
+		if tc.MinLevel > 0 {
+			out.MinLevel = tc.MinLevel
+		}
+
+		if tc.MaxLevel > 0 {
+			out.MaxLevel = tc.MaxLevel
+		}
+	}
+
+	return &out
+}
+
+// Table of contents entry
+type TOCEntry struct {
+	Title    string
+	ID       string
+	Level    int
+	Href     string // Link to the heading, including the page link
+	Children []*TOCEntry
+}
+
+// Builds a table of contents tree from the headings of a markdown document
+func NewTOC(doc *blackfriday.Node, c *TOCConfig) []*TOCEntry {
+	root := &TOCEntry{Children: make([]*TOCEntry, 0)}
+
+	if c == nil || (c.Disabled != nil && *c.Disabled) {
+		return root.Children
+	}
+
+	stack := []*TOCEntry{root}
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering || node.Type != blackfriday.Heading {
+			return blackfriday.GoToNext
+		}
+
+		if node.Level < c.MinLevel || node.Level > c.MaxLevel || node.HeadingID == "" {
+			return blackfriday.SkipChildren
+		}
+
+		entry := &TOCEntry{
+			Title:    nodeText(node),
+			ID:       node.HeadingID,
+			Level:    node.Level,
+			Children: make([]*TOCEntry, 0),
+		}
+
+		for len(stack) > 1 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, entry)
+		stack = append(stack, entry)
+
+		return blackfriday.SkipChildren
+	})
+
+	return root.Children
+}
+
+// Sets the Href of all entries to the page link followed by the heading anchor
+func setTOCLinks(entries []*TOCEntry, link string) {
+	for _, e := range entries {
+		e.Href = link + "#" + e.ID
+		setTOCLinks(e.Children, link)
+	}
+}
+
+// Makes heading IDs unique within the document, using the same suffixes as the blackfriday HTML renderer
+func uniqueHeadingIDs(doc *blackfriday.Node) {
+	seen := make(map[string]bool)
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering || node.Type != blackfriday.Heading || node.HeadingID == "" {
+			return blackfriday.GoToNext
+		}
+
+		id := node.HeadingID
+
+		for i := 1; seen[id]; i++ {
+			id = fmt.Sprintf("%s-%d", node.HeadingID, i)
+		}
+
+		seen[id] = true
+		node.HeadingID = id
+
+		return blackfriday.SkipChildren
+	})
+}
+
+// Returns the text content of a node and its children
+func nodeText(node *blackfriday.Node) string {
+	buff := bytes.NewBuffer(make([]byte, 0))
+
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (n.Type == blackfriday.Text || n.Type == blackfriday.Code) {
+			buff.Write(n.Literal)
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	return buff.String()
+}
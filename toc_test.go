@@ -0,0 +1,46 @@
+package zmdocs
+
+import (
+	"gopkg.in/yaml.v2"
+	"testing"
+)
+
+func TestTOCConfigMerge(t *testing.T) {
+	parse := func(s string) *TOCConfig {
+		t.Helper()
+
+		var c TOCConfig
+
+		if err := yaml.Unmarshal([]byte(s), &c); err != nil {
+			t.Fatal(err)
+		}
+
+		return &c
+	}
+
+	tests := []struct {
+		name     string
+		global   *TOCConfig
+		page     *TOCConfig
+		disabled bool
+		maxLevel int
+	}{
+		{name: "defaults", maxLevel: DefaultTOCMaxLevel},
+		{name: "disabled globally", global: parse("disabled: true"), disabled: true, maxLevel: DefaultTOCMaxLevel},
+		{name: "page sets levels only", global: parse("disabled: true"), page: parse("maxLevel: 4"), disabled: true, maxLevel: 4},
+		{name: "page enables", global: parse("disabled: true"), page: parse("disabled: false"), maxLevel: DefaultTOCMaxLevel},
+		{name: "page disables", global: parse("maxLevel: 4"), page: parse("disabled: true"), disabled: true, maxLevel: 4},
+	}
+
+	for _, tt := range tests {
+		c := tt.global.merge(tt.page)
+
+		if disabled := c.Disabled != nil && *c.Disabled; disabled != tt.disabled {
+			t.Errorf("%s: expected disabled to be %t", tt.name, tt.disabled)
+		}
+
+		if c.MaxLevel != tt.maxLevel {
+			t.Errorf("%s: expected max level %d, got %d", tt.name, tt.maxLevel, c.MaxLevel)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package zmdocs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the directory the latest version is also built in
+const LatestVersionAlias = "latest"
+
+// Versioned documentation configuration
+type VersionsConfig struct {
+	Latest string     `yaml:"latest"` // Name of the latest version, defaults to the first version in the list
+	List   []*Version `yaml:"list"`   // Versions to build
+}
+
+// Documentation version built from a git tag or branch
+type Version struct {
+	Name string `yaml:"name"` // Version name, used as the URL prefix
+	Ref  string `yaml:"ref"`  // Git tag, branch or commit to read the sources from. Defaults to the name.
+}
+
+// Version switcher entry available to templates
+type VersionLink struct {
+	Name    string
+	Link    string
+	Current bool
+	Latest  bool
+}
+
+// Returns the name of the latest version
+func (c *VersionsConfig) LatestName() string {
+	if c == nil {
+		return ""
+	}
+
+	if c.Latest != "" {
+		return c.Latest
+	}
+
+	if len(c.List) > 0 {
+		return c.List[0].Name
+	}
+
+	return ""
+}
+
+// Sets the version fields of the render context. rootURL is the link to the root of the current version.
+func (c *VersionsConfig) apply(ctx *RenderContext, current, rootURL string) {
+	if c == nil || current == "" {
+		return
+	}
+
+	latest := c.LatestName()
+
+	ctx.Version = current
+	ctx.Outdated = current != latest
+	ctx.LatestURL = rootURL + "../" + LatestVersionAlias + "/"
+	ctx.Versions = make([]*VersionLink, len(c.List))
+
+	for i, v := range c.List {
+		ctx.Versions[i] = &VersionLink{
+			Name:    v.Name,
+			Link:    rootURL + "../" + v.Name + "/",
+			Current: v.Name == current,
+			Latest:  v.Name == latest,
+		}
+	}
+}
+
+// Builds the docs for every configured version. The sources of each version are read from the git
+// repository containing the config file, the working tree is not touched. Each version is written to
+// `<outDir>/<name>/`, the latest version is also written to `<outDir>/latest/`. jobs overrides the number
+// of pages rendered in parallel when it's greater than 0.
+func GenerateVersions(configPath, outDir string, jobs int) error {
+	config, err := NewConfigFromFile(configPath)
+
+	if err != nil {
+		return err
+	}
+
+	if config.Versions == nil || len(config.Versions.List) == 0 {
+		return errors.New("no versions are configured")
+	}
+
+	if outDir == "" {
+		outDir = config.OutDir
+	}
+
+	topLevel, err := git(config.RootDir, "rev-parse", "--show-toplevel")
+
+	if err != nil {
+		return fmt.Errorf("unable to find git repository: %s", err.Error())
+	}
+
+	repoDir := strings.TrimSpace(string(topLevel))
+
+	// the config dir may be a symlink (e.g. /tmp on macOS), resolve it before comparing with the repo dir
+	rootDir, err := filepath.EvalSymlinks(config.RootDir)
+
+	if err != nil {
+		return err
+	}
+
+	prefix, err := filepath.Rel(repoDir, rootDir)
+
+	if err != nil {
+		return err
+	}
+
+	latest := config.Versions.LatestName()
+
+	for _, v := range config.Versions.List {
+		ref := v.Ref
+
+		if ref == "" {
+			ref = v.Name
+		}
+
+		l := log.WithFields(logrus.Fields{
+			"version": v.Name,
+			"ref":     ref,
+		})
+
+		l.Info("building version")
+
+		targets := []string{v.Name}
+
+		if v.Name == latest {
+			targets = append(targets, LatestVersionAlias)
+		}
+
+		if err := generateVersion(configPath, repoDir, prefix, ref, targets, func(c *ParserConfig, target string) {
+			c.OutDir = filepath.Join(outDir, target)
+			c.Versions = config.Versions
+			c.Version = v.Name
+			// edit links point at the sources of this version
+			c.RepoBranch = ref
+
+			if jobs > 0 {
+				c.Jobs = jobs
+			}
+
+			c.BaseURL = versionBaseURL(config.BaseURL, target)
+		}); err != nil {
+			return fmt.Errorf("unable to build version %s: %s", v.Name, err.Error())
+		}
+	}
+
+	if latest != "" {
+		if _, err := writeRedirect(DiskFS{}, outDir, LatestVersionAlias+"/"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the base URL of the version built in target, empty if there is no base URL. It ends with a slash
+// so relative page links resolve inside the version.
+func versionBaseURL(baseURL, target string) string {
+	if baseURL == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + target + "/"
+}
+
+// Exports the tree of ref to a temporary directory and renders it once for every target.
+// The config is loaded from the exported tree, or from configPath if the tree has none, and passed to configure.
+func generateVersion(configPath, repoDir, prefix, ref string, targets []string, configure func(c *ParserConfig, target string)) error {
+	tmpDir, err := ioutil.TempDir("", "zmdocs-version-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	if err := exportGitTree(repoDir, ref, tmpDir); err != nil {
+		return err
+	}
+
+	versionRoot := filepath.Join(tmpDir, prefix)
+	versionConfigPath := filepath.Join(versionRoot, filepath.Base(configPath))
+
+	if _, err := os.Stat(versionConfigPath); os.IsNotExist(err) {
+		// older versions may not have a config file, use the current one
+		data, err := ioutil.ReadFile(configPath)
+
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(versionRoot, 0755); err != nil {
+			return err
+		} else if err := ioutil.WriteFile(versionConfigPath, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, target := range targets {
+		c, err := NewConfigFromFile(versionConfigPath)
+
+		if err != nil {
+			return err
+		}
+
+		configure(c, target)
+
+		if c.Sitemap != nil {
+			c.Sitemap.history = &gitHistory{repoDir: repoDir, ref: ref, treeDir: tmpDir}
+		}
+
+		p := NewParser(c)
+
+		if err := p.LoadSourceFiles(); err != nil {
+			return fmt.Errorf("unable to load files: %s", err.Error())
+		} else if rnd, err := p.Renderer(); err != nil {
+			return fmt.Errorf("unable to create renderer: %s", err.Error())
+		} else if err := rnd.Render(); err != nil {
+			return fmt.Errorf("unable to render files: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Writes the files of the git tree at ref to dest, reading them from the object database
+func exportGitTree(repoDir, ref, dest string) error {
+	data, err := git(repoDir, "archive", "--format=tar", ref)
+
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", ref, err.Error())
+	}
+
+	tr := tar.NewReader(bytes.NewReader(data))
+
+	for {
+		hdr, err := tr.Next()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(hdr.Name))
+
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in archive: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0755|0644)
+
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(f, tr); err != nil {
+				_ = f.Close()
+				return err
+			}
+
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Runs a git command in dir and returns its output
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stderr := bytes.NewBuffer(make([]byte, 0))
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", err.Error(), msg)
+		}
+
+		return nil, err
+	}
+
+	return out, nil
+}
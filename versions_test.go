@@ -0,0 +1,105 @@
+package zmdocs
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestVersionBaseURL(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "zmdocs")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range map[string]string{
+		"index.md": "# Home\n\n[Guide](guide.md)\n",
+		"guide.md": "# Guide\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(rootDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewParser(&ParserConfig{
+		RootDir: rootDir,
+		OutDir:  filepath.Join(rootDir, "out", "v2"),
+		BaseURL: versionBaseURL("https://ex.com/docs", "v2"),
+		Pages: []*Page{
+			{BasePage: BasePage{SourceFile: "index.md", Path: "/"}},
+			{BasePage: BasePage{SourceFile: "guide.md", Path: "guide"}},
+		},
+	})
+
+	if err := p.LoadSourceFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	rnd, err := p.Renderer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewMemFS()
+	rnd.FS = fs
+
+	if err := rnd.Render(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(filepath.Join(rootDir, "out", "v2", "index.html"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(data)
+
+	if !strings.Contains(html, `<base href="https://ex.com/docs/v2/" />`) {
+		t.Fatalf("expected a base tag for the version, got:\n%s", html)
+	}
+
+	m := regexp.MustCompile(`<a href="([^"]*)">Guide</a>`).FindStringSubmatch(html)
+
+	if m == nil {
+		t.Fatalf("expected a link to the guide, got:\n%s", html)
+	}
+
+	base, _ := url.Parse("https://ex.com/docs/v2/")
+	link, err := base.Parse(m[1])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if link.String() != "https://ex.com/docs/v2/guide" {
+		t.Errorf("expected the guide link to resolve inside the version, got %s", link)
+	}
+}
+
+func TestWriteRedirectEscapesTarget(t *testing.T) {
+	fs := NewMemFS()
+	dest, err := writeRedirect(fs, "/out", `v1/"><script>alert(1)</script>`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(dest)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "<script>") || !strings.Contains(string(data), `href="v1/&#34;&gt;&lt;script&gt;`) {
+		t.Errorf("expected the target to be escaped, got:\n%s", data)
+	}
+}
@@ -0,0 +1,118 @@
+package zmdocs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Returns the directories where changes can affect the build: the root directory, the directories of page
+// sources and templates, and every directory under globs, partials, assets and Go packages, including ones
+// that don't contain any matching file yet. Hidden directories and the output directory are left out.
+func (p *Parser) WatchDirs() []string {
+	dirs := make(map[string]bool)
+	c := p.Config
+
+	add := func(dir string) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && !p.InOutDir(dir) {
+			dirs[filepath.Clean(dir)] = true
+		}
+	}
+
+	addTree := func(dir string) {
+		_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+
+			if path != dir && (strings.HasPrefix(info.Name(), ".") || p.InOutDir(path)) {
+				return filepath.SkipDir
+			}
+
+			add(path)
+
+			return nil
+		})
+	}
+
+	abs := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(c.RootDir, path)
+	}
+
+	add(c.RootDir)
+
+	roots := []string{c.RootDir}
+
+	if len(c.Languages) > 0 {
+		roots = make([]string, len(c.Languages))
+
+		for i, lang := range c.Languages {
+			roots[i] = filepath.Join(c.RootDir, lang.Root)
+		}
+	}
+
+	for _, root := range roots {
+		for _, pg := range c.Pages {
+			add(filepath.Dir(filepath.Join(root, pg.SourceFile)))
+		}
+
+		for _, ap := range c.AutoPages {
+			addTree(globBaseDir(filepath.Join(root, ap.SourceGlob)))
+		}
+	}
+
+	for _, t := range c.Templates {
+		add(filepath.Dir(t.SourceFile))
+	}
+
+	if c.PartialsDir != "" {
+		addTree(c.PartialsDir)
+	}
+
+	for _, a := range c.Assets {
+		if info, err := os.Stat(abs(a)); err == nil && info.IsDir() {
+			addTree(abs(a))
+		} else {
+			addTree(globBaseDir(abs(a)))
+		}
+	}
+
+	for _, src := range c.GoPackages {
+		addTree(abs(src.Dir))
+	}
+
+	for _, src := range c.OpenAPI {
+		add(filepath.Dir(abs(src.SourceFile)))
+	}
+
+	out := make([]string, 0, len(dirs))
+
+	for dir := range dirs {
+		out = append(out, dir)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+// Reports whether path is the output directory or inside of it
+func (p *Parser) InOutDir(path string) bool {
+	if p.Config.OutDir == "" {
+		return false
+	}
+
+	return pathInside(p.Config.OutDir, path)
+}
+
+// Reports whether path is dir or inside of it
+func pathInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}